@@ -74,11 +74,15 @@ func (gm * GridManager) IsNotTeleporting(origCoord shared.Coord, newCoord shared
 }
 
 func (gm * GridManager) GetRandomValidPos() (shared.Coord) {
+	return gm.randomValidPos(rand.Intn)
+}
+
+func (gm * GridManager) randomValidPos(intn func(int) int) (shared.Coord) {
 	var posX int
 	var posY int
 	for {
-		posX = rand.Intn(gm.x)
-		posY = rand.Intn(gm.y)
+		posX = intn(gm.x)
+		posY = intn(gm.y)
 		if gm.IsValidMove(shared.Coord{posX, posY}) {
 			break
 		}
@@ -88,6 +92,16 @@ func (gm * GridManager) GetRandomValidPos() (shared.Coord) {
 
 
 func (gm * GridManager) GetNewPos(oldMove shared.Coord) (shared.Coord) {
+	return gm.getNewPos(oldMove, rand.Intn)
+}
+
+// Picks a new position far from oldMove as GetNewPos does, but using r, so the same r always picks the same position
+// Returns the new position
+func (gm * GridManager) GetNewPosFrom(oldMove shared.Coord, r *rand.Rand) (shared.Coord) {
+	return gm.getNewPos(oldMove, r.Intn)
+}
+
+func (gm * GridManager) getNewPos(oldMove shared.Coord, intn func(int) int) (shared.Coord) {
 	posX := gm.x - oldMove.X
 	posY := gm.y - oldMove.Y
 
@@ -97,7 +111,7 @@ func (gm * GridManager) GetNewPos(oldMove shared.Coord) (shared.Coord) {
 
 	var pos shared.Coord
 	for {
-		pos = gm.GetRandomValidPos()
+		pos = gm.randomValidPos(intn)
 
 		if gm.isFarAway(oldMove.X, oldMove.Y, pos.X, pos.Y) {
 			break
@@ -106,6 +120,54 @@ func (gm * GridManager) GetNewPos(oldMove shared.Coord) (shared.Coord) {
 	return pos
 }
 
+// Finds the wolf other than self standing on coord, given every player's location. Prey do not take up a cell.
+// Returns the wolf's identifier and true if there is one.
+func (gm * GridManager) OccupiedBy(coord shared.Coord, self string, locs map[string]shared.Coord) (string, bool) {
+	for id, loc := range locs {
+		if id != self && !shared.IsPreyId(id) && loc == coord {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// Returns the cells that can be moved to from coord in one step: coord itself first, then its neighbours that are
+// valid moves
+func (gm * GridManager) Neighbours(coord shared.Coord) ([]shared.Coord) {
+	cells := []shared.Coord{coord}
+	for _, step := range []shared.Coord{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+		next := shared.Coord{X: coord.X + step.X, Y: coord.Y + step.Y}
+		if gm.IsValidMove(next) {
+			cells = append(cells, next)
+		}
+	}
+	return cells
+}
+
+// Works out how many steps it takes to walk, around walls, from the nearest of from to each cell
+// Returns the number of steps by cell; cells that can not be reached from any of from are left out
+func (gm * GridManager) Distances(from []shared.Coord) (map[shared.Coord]int) {
+	dist := make(map[shared.Coord]int)
+	var queue []shared.Coord
+	for _, start := range from {
+		if _, ok := dist[start]; !ok {
+			dist[start] = 0
+			queue = append(queue, start)
+		}
+	}
+	for len(queue) > 0 {
+		cell := queue[0]
+		queue = queue[1:]
+		for _, next := range gm.Neighbours(cell)[1:] {
+			if _, ok := dist[next]; !ok {
+				dist[next] = dist[cell] + 1
+				queue = append(queue, next)
+			}
+		}
+	}
+	return dist
+}
+
 // Checks that a given move is valid by checking if it is in bounds and also not a wall
 // Returns true of the move is valid, false otherwise.
 func (gm * GridManager) IsValidMove(coord shared.Coord) (bool) {
@@ -7,6 +7,8 @@ import (
 	"crypto/x509"
 	"log"
 	"crypto/elliptic"
+	"crypto/sha256"
+	"fmt"
 	"math/big"
 )
 
@@ -59,16 +61,47 @@ func CheckKeyRestore(publicKey *ecdsa.PublicKey, privateKey *ecdsa.PrivateKey) b
 	return true
 }
 
+// Signs a SHA-256 hash of payload with privateKey. ECDSA only looks at as many bytes of what it signs as the curve is
+// long, so longer payloads have to be hashed first or most of them would not be signed at all.
+// Returns the signature as strings, ready to send
+func Sign(privateKey *ecdsa.PrivateKey, payload []byte) (r string, s string, err error) {
+	hash := sha256.Sum256(payload)
+	rBigInt, sBigInt, err := ecdsa.Sign(rand.Reader, privateKey, hash[:])
+	if err != nil {
+		return "", "", err
+	}
+	return rBigInt.String(), sBigInt.String(), nil
+}
+
+// Checks that r and s are publicKey's signature over payload, as made by Sign
+// Returns true if the signature is valid
+func Verify(publicKey *ecdsa.PublicKey, payload []byte, r string, s string) bool {
+	rBigInt := new(big.Int)
+	sBigInt := new(big.Int)
+	if _, err := fmt.Sscan(r, rBigInt); err != nil {
+		return false
+	}
+	if _, err := fmt.Sscan(s, sBigInt); err != nil {
+		return false
+	}
+	hash := sha256.Sum256(payload)
+	return ecdsa.Verify(publicKey, hash[:], rBigInt, sBigInt)
+}
+
 // Encodes a public/private keypair to strings for easier storage and sending
 // https://stackoverflow.com/questions/21322182/how-to-store-ecdsa-private-key-in-go
 func Encode(privateKey *ecdsa.PrivateKey, publicKey *ecdsa.PublicKey) (privateKeyString string, publicKeyString string) {
 	x509Encoded, _ := x509.MarshalECPrivateKey(privateKey)
 	pemEncoded := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: x509Encoded})
 
+	return string(pemEncoded), EncodePublicKey(publicKey)
+}
+
+// Encodes a public key to a string the way Encode does, as move commits carry it
+func EncodePublicKey(publicKey *ecdsa.PublicKey) string {
 	x509EncodedPub, _ := x509.MarshalPKIXPublicKey(publicKey)
 	pemEncodedPub := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: x509EncodedPub})
-
-	return string(pemEncoded), string(pemEncodedPub)
+	return string(pemEncodedPub)
 }
 
 // Generates a new public/private keypair and returns them
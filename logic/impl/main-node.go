@@ -3,6 +3,7 @@ package impl
 import (
 	"../../shared"
 	"../../geometry"
+	"../../peer"
 	"fmt"
 	"crypto/ecdsa"
 	"time"
@@ -36,6 +37,9 @@ type PlayerNode struct {
 
 	// The game configuration provided upon registration from the server. Includes wall locations and board size.
 	GameConfig shared.InitialState
+
+	// This node's moves that are shown ahead of being committed
+	predicted  *Prediction
 }
 
 // Creates the main logic node and required interfaces with the arguments passed in logic-node.go
@@ -50,7 +54,7 @@ func CreatePlayerNode(nodeListenerAddr, playerListenerAddr string,
 
 	// Start the node to node interface
 	nodeInterface := CreateNodeCommInterface(pubKey, privKey, serverAddr)
-	addr, listener := StartListenerUDP(nodeListenerAddr)
+	addr, listener := peer.StartListenerUDP(nodeListenerAddr)
 
 	nodeInterface.LocalAddr = addr
 	nodeInterface.IncomingMessages = listener
@@ -63,6 +67,11 @@ func CreatePlayerNode(nodeListenerAddr, playerListenerAddr string,
 	// Register with server, update info
 	uniqueId := nodeInterface.ServerRegister()
 	go nodeInterface.SendHeartbeat()
+	go nodeInterface.SendPositionSummaries()
+	go nodeInterface.RunTicks()
+	go nodeInterface.RunLedgerSync()
+	go nodeInterface.RunAntiEntropy()
+	go nodeInterface.ReportDiagnostics()
 
 	// Startup Pixel interface + listening
 	pixelInterface := CreatePixelInterface(playerCommChannel, playerSendChannel,
@@ -70,7 +79,7 @@ func CreatePlayerNode(nodeListenerAddr, playerListenerAddr string,
 
 	//// Make a gameState
 	playerLocs := make(map[string]shared.Coord)
-	playerLocs["prey"] = shared.Coord{5,5}
+	playerLocs[shared.PreyIdentifier] = shared.Coord{5,5}
 	playerLocs[uniqueId] = shared.Coord{1,1}
 
 	playerScores := make(map[string]int)
@@ -88,13 +97,14 @@ func CreatePlayerNode(nodeListenerAddr, playerListenerAddr string,
 	// Create player node
 	pn := PlayerNode{
 		pixelInterface:    pixelInterface,
-		nodeInterface:     &nodeInterface,
+		nodeInterface:     nodeInterface,
 		playerCommChannel: playerCommChannel,
 		playerSendChannel: playerSendChannel,
 		geo:               geometry.CreateNewGridManager(nodeInterface.Config.InitState.Settings),
 		GameState:         gameState,
 		Identifier:        uniqueId,
 		GameConfig:        nodeInterface.Config.InitState,
+		predicted:         NewPrediction(playerLocs[uniqueId]),
 	}
 
 	// Allow the node-node interface to refer back to this node
@@ -110,24 +120,25 @@ func (pn * PlayerNode) RunGame(playerListener string) {
 	fmt.Println("listener running")
 
 	for {
-		message := <-pn.playerCommChannel
+		var message string
+		select {
+		case message = <-pn.playerCommChannel:
+		case move := <-pn.nodeInterface.MovesSettled:
+			pn.MoveSettled(move)
+			continue
+		case <-pn.nodeInterface.Done():
+			return
+		}
 		switch message {
 		case "quit":
-			break
+			pn.Close()
+			return
 		default:
 			move, didMove := pn.movePlayer(message)
 			if didMove {
-				pn.nodeInterface.SendMoveToNodes(&move)
-			}
-			if pn.nodeInterface.CheckGotPrey(move) == nil {
-				fmt.Println("Got the prey")
-				pn.GameState.PlayerScores.Lock()
-				pn.GameState.PlayerScores.Data[pn.Identifier] += pn.GameConfig.CatchWorth
-				pn.nodeInterface.SendPreyCaptureToNodes(&move, pn.GameState.PlayerScores.Data[pn.Identifier])
-				pn.nodeInterface.RW.Add("captured_prey", sequenceNumber, &move)
-				fmt.Println(pn.GameState.PlayerScores.Data[pn.Identifier])
-				pn.GameState.PlayerScores.Unlock()
+				pn.sendPredictedMove(move, []string{message})
 			}
+			pn.captureIfGotPrey(move)
 			// pn.pixelInterface.SendPlayerGameState(pn.GameState)
 		}
 	}
@@ -137,14 +148,32 @@ func (pn * PlayerNode) RunGame(playerListener string) {
 // Given a string "up"/"down"/"left"/"right", changes the player state to make that move iff that move is valid
 // (not into a wall, out of bounds)
 func (pn * PlayerNode) movePlayer(move string) (newPos shared.Coord, changed bool) {
-	// Get current player state
+	playerLoc := pn.currentPosition()
+
+	if newPosition, ok := pn.nextPosition(playerLoc, move); ok {
+		return newPosition, true
+	}
+	return playerLoc, false
+}
+
+// Returns where this node's wolf is. When ticking, its own moves are only applied to the game state once the tick
+// they are for is simulated, so the wolf is where the last move it predicted put it.
+func (pn *PlayerNode) currentPosition() shared.Coord {
+	if pn.nodeInterface != nil && pn.nodeInterface.Ticking() {
+		pn.predicted.Lock()
+		defer pn.predicted.Unlock()
+		return pn.predicted.Last()
+	}
 	pn.GameState.PlayerLocs.RLock()
-	playerLoc := pn.GameState.PlayerLocs.Data[pn.Identifier]
-	pn.GameState.PlayerLocs.RUnlock()
+	defer pn.GameState.PlayerLocs.RUnlock()
+	return pn.GameState.PlayerLocs.Data[pn.Identifier]
+}
 
-	originalPosition := shared.Coord{X: playerLoc.X, Y: playerLoc.Y}
+// Returns the position move ("up"/"down"/"left"/"right") takes this player to from originalPosition, and whether the
+// move is valid there (not into a wall, out of bounds, or onto a cell the occupancy rule does not allow)
+func (pn *PlayerNode) nextPosition(originalPosition shared.Coord, move string) (shared.Coord, bool) {
 	// Calculate new position with move
-	newPosition := shared.Coord{X: playerLoc.X, Y: playerLoc.Y}
+	newPosition := originalPosition
 	switch move {
 	case "up":
 		newPosition.Y = newPosition.Y + 1
@@ -155,14 +184,28 @@ func (pn * PlayerNode) movePlayer(move string) (newPos shared.Coord, changed boo
 	case "right":
 		newPosition.X = newPosition.X + 1
 	}
-	// Check new move is valid, if so update player position
-	if pn.geo.IsValidMove(newPosition) && pn.geo.IsNotTeleporting(originalPosition, newPosition){
-		//pn.GameState.PlayerLocs.Lock()
-		//pn.GameState.PlayerLocs.Data[pn.Identifier] = newPosition
-		//pn.GameState.PlayerLocs.Unlock()
+	// Check new move is valid and allowed by the occupancy rule
+	if pn.geo.IsValidMove(newPosition) && pn.geo.IsNotTeleporting(originalPosition, newPosition) &&
+		pn.canOccupy(newPosition) {
 		return newPosition, true
 	}
-	return playerLoc, false
+	return originalPosition, false
+}
+
+// Returns true if the occupancy rule lets this player move to pos; bouncing back is the same as not moving
+func (pn *PlayerNode) canOccupy(pos shared.Coord) bool {
+	if pn.nodeInterface == nil {
+		return true
+	}
+	bounce, err := pn.nodeInterface.CheckOccupancy(pn.Identifier, pos)
+	return !bounce && err == nil
+}
+
+// Leaves the game: closes the node-node interface (which tells the other nodes and the server we are leaving) and
+// the connection to the pixel node. RunGame and RunBotGame return once this has been called.
+func (pn *PlayerNode) Close() {
+	pn.nodeInterface.Close()
+	pn.pixelInterface.Close()
 }
 
 // GETTERS
@@ -187,9 +230,10 @@ func (pn *PlayerNode) GetPlayerCommChannel() (chan string) {
 
 // Runs a bot game
 func (pn * PlayerNode) RunBotGame(playerListener string) {
-	for {
-		myState := pn.GameState.PlayerLocs.Data[pn.Identifier]
-		prey := pn.GameState.PlayerLocs.Data["prey"]
+	for !pn.nodeInterface.IsClosed() {
+		pn.settleMoves()
+		myState := pn.currentPosition()
+		prey := pn.nearestPrey(myState)
 		command := "still"
 		minVal := abs(myState.X-prey.X)+ abs(myState.Y-prey.Y)
 		if minVal <= 3{
@@ -219,23 +263,46 @@ func (pn * PlayerNode) RunBotGame(playerListener string) {
 		}
 		move, ok := pn.movePlayer(command)
 		if ok{
-			pn.nodeInterface.SendMoveToNodes(&move)
+			pn.sendPredictedMove(move, []string{command})
 			pn.nodeInterface.GameStateToSend = make(chan bool, 30)
 			fmt.Println("movin' bot", command)
 		}
-		if pn.nodeInterface.CheckGotPrey(move) == nil {
-			fmt.Println("Got the prey")
-			pn.GameState.PlayerScores.Lock()
-			pn.GameState.PlayerScores.Data[pn.Identifier] += pn.GameConfig.CatchWorth
-			pn.nodeInterface.SendPreyCaptureToNodes(&move, pn.GameState.PlayerScores.Data[pn.Identifier])
-			pn.nodeInterface.RW.Add("captured_prey", sequenceNumber, &move)
-			fmt.Println(pn.GameState.PlayerScores.Data[pn.Identifier])
-			pn.GameState.PlayerScores.Unlock()
-		}
+		pn.captureIfGotPrey(move)
 		// Take move off the channel
 		time.Sleep(time.Millisecond*400)
 	}
 }
+// Returns the position of the prey closest to from, or from itself if there is no prey
+func (pn *PlayerNode) nearestPrey(from shared.Coord) shared.Coord {
+	pn.GameState.PlayerLocs.RLock()
+	defer pn.GameState.PlayerLocs.RUnlock()
+	nearest, minDist := from, -1
+	for id, loc := range pn.GameState.PlayerLocs.Data {
+		dist := abs(from.X-loc.X) + abs(from.Y-loc.Y)
+		if shared.IsPreyId(id) && (minDist == -1 || dist < minDist) {
+			nearest, minDist = loc, dist
+		}
+	}
+	return nearest
+}
+
+// Captures the prey this node's wolf is on, if there is one: the capture is sent to every other node to confirm, and
+// is added to the ledger (which updates the score) once a quorum of them have. Another wolf may already hold the
+// capture at this prey sequence number.
+func (pn *PlayerNode) captureIfGotPrey(move shared.Coord) {
+	prey, err := pn.nodeInterface.CheckGotPrey(move)
+	if err != nil {
+		return
+	}
+	event, err := pn.nodeInterface.ProposeCapture(prey, move, pn.nodeInterface.RW.PreySeq(prey))
+	if err != nil {
+		fmt.Println("Could not capture the prey:", err)
+		return
+	}
+	fmt.Println("Got the prey, waiting for confirmations")
+	pn.nodeInterface.SendPreyCaptureToNodes(event)
+}
+
 func abs(num int)int {
 	if num <0{
 		return -num
@@ -0,0 +1,124 @@
+package impl
+
+import (
+	"sort"
+	"time"
+	"../../shared"
+)
+
+// How long one of this node's moves waits for a quorum of ACKs before it fails
+const ACK_TIMEOUT = time.Second
+
+// How often ManageAcks checks its pending moves for ones that are past their deadline
+const ACK_CHECK_INTERVAL = 100 * time.Millisecond
+
+// What became of one of this node's moves
+type MoveOutcome int
+
+const (
+	// ACKed by a quorum of the nodes it was sent to; the move is applied
+	MoveCommitted MoveOutcome = iota
+	// Not ACKed by a quorum before its deadline, or rejected by another node; the wolf goes back to where it was
+	MoveFailed
+)
+
+// One of this node's moves once it has settled
+type SettledMove struct {
+	Seq     uint64
+	Coord   shared.Coord
+	Outcome MoveOutcome
+}
+
+// This node's moves that are waiting on ACKs, by sequence number. Only used by the ManageAcks routine.
+type PendingMoves struct {
+	bySeq map[uint64]*pendingMove
+}
+
+type pendingMove struct {
+	coord    shared.Coord
+	// Nodes the move was not sent to, as they were outside the interest radius
+	far      map[string]bool
+	// Nodes that have ACKed the move
+	acks     map[string]bool
+	deadline time.Time
+}
+
+// Creates an empty pending move table
+func NewPendingMoves() *PendingMoves {
+	return &PendingMoves{bySeq: make(map[uint64]*pendingMove)}
+}
+
+// Returns the number of ACKs a move needs when it was sent to recipients nodes: a majority of them. A move sent to no
+// one needs none.
+func AckQuorum(recipients int) int {
+	if recipients <= 0 {
+		return 0
+	}
+	return recipients/2 + 1
+}
+
+// Adds this node's move at seq, which was sent to every node but the far ones, and must be ACKed by deadline
+func (p *PendingMoves) Add(seq uint64, coord shared.Coord, far map[string]bool, deadline time.Time) {
+	p.bySeq[seq] = &pendingMove{coord: coord, far: far, acks: make(map[string]bool), deadline: deadline}
+}
+
+// Records identifier's ACK of the move at seq.
+// Returns false if there is no such move waiting on ACKs, as when the ACK arrives after the move settled
+func (p *PendingMoves) Ack(seq uint64, identifier string) bool {
+	move, ok := p.bySeq[seq]
+	if !ok {
+		return false
+	}
+	move.acks[identifier] = true
+	return true
+}
+
+// Fails the move at seq straight away, as when another node rejected it.
+// Returns the failed move, or false if there is no such move waiting on ACKs
+func (p *PendingMoves) Fail(seq uint64) (SettledMove, bool) {
+	move, ok := p.bySeq[seq]
+	if !ok {
+		return SettledMove{}, false
+	}
+	delete(p.bySeq, seq)
+	return SettledMove{seq, move.coord, MoveFailed}, true
+}
+
+// Returns the number of moves still waiting on ACKs
+func (p *PendingMoves) Len() int {
+	return len(p.bySeq)
+}
+
+// Settles every pending move that has a quorum of ACKs, or is past its deadline at now. The quorum is worked out from
+// the current membership: members is the number of other nodes in the game, and isMember tells whether a node is
+// still one of them. ACKs from nodes that have left no longer count, and nodes that have left no longer need to ACK.
+// Returns the moves that settled, in sequence number order
+func (p *PendingMoves) Settle(members int, isMember func(identifier string) bool, now time.Time) []SettledMove {
+	var settled []SettledMove
+	for seq, move := range p.bySeq {
+		recipients := members
+		for id := range move.far {
+			if isMember(id) {
+				recipients--
+			}
+		}
+		acks := 0
+		for id := range move.acks {
+			if isMember(id) && !move.far[id] {
+				acks++
+			}
+		}
+		if acks >= AckQuorum(recipients) {
+			settled = append(settled, SettledMove{seq, move.coord, MoveCommitted})
+		} else if !now.Before(move.deadline) {
+			settled = append(settled, SettledMove{seq, move.coord, MoveFailed})
+		} else {
+			continue
+		}
+		delete(p.bySeq, seq)
+	}
+	sort.Slice(settled, func(i, j int) bool {
+		return settled[i].Seq < settled[j].Seq
+	})
+	return settled
+}
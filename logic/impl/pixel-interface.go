@@ -27,6 +27,9 @@ type PixelInterface struct {
 
 	// The ID of this logic node
 	Id string
+
+	// Closed by Close() to stop the listener and the routine sending game states to the pixel node
+	quit			  chan struct{}
 }
 
 // Creates & returns a pixel interface with a channel to send string information to the main node over
@@ -34,7 +37,7 @@ type PixelInterface struct {
 func CreatePixelInterface(playerCommChannel chan string, playerSendChannel chan shared.GameState,
 	settings shared.InitialGameSettings, id string) PixelInterface {
 	pi := PixelInterface{playerCommChannel: playerCommChannel,playerSendChannel:playerSendChannel, Id: id,
-	gameConfig: settings}
+	gameConfig: settings, quit: make(chan struct{})}
 	return pi
 }
 
@@ -42,16 +45,23 @@ func CreatePixelInterface(playerCommChannel chan string, playerSendChannel chan
 // to the pixel node
 func (pi *PixelInterface) waitForGameStates() {
 	for {
-		state := <-pi.playerSendChannel
-
+		var state shared.GameState
+		select {
+		case state = <-pi.playerSendChannel:
+		case <-pi.quit:
+			return
+		}
 
 		state.PlayerLocs.Lock()
 		state.PlayerScores.Lock()
 
-		// Create the player map without without this node or prey node
+		// Split the other players from the prey, leaving out this node
 		otherPlayers := make(map[string]shared.Coord)
+		prey := make(map[string]shared.Coord)
 		for key, value := range state.PlayerLocs.Data {
-			if key != pi.Id && key != "prey" {
+			if shared.IsPreyId(key) {
+				prey[key] = value
+			} else if key != pi.Id {
 				otherPlayers[key] = value
 			}
 		}
@@ -67,7 +77,7 @@ func (pi *PixelInterface) waitForGameStates() {
 
 		renderState := shared.GameRenderState{
 			PlayerLoc:    state.PlayerLocs.Data[pi.Id],
-			Prey:         state.PlayerLocs.Data["prey"],
+			Prey:         prey,
 			OtherPlayers: otherPlayers,
 			Scores: otherScores,
 		}
@@ -98,6 +108,10 @@ func (pi * PixelInterface) RunPlayerListener(receivingAddr string) {
 	pi.pixelListener = playerInput
 	fmt.Println("about to get to conn")
 	conn := pi.GetTCPConn()
+	if conn == nil {
+		// Closed before a pixel node ever connected
+		return
+	}
 	fmt.Println("got conn")
 	pi.pixelWriter = conn
 
@@ -110,6 +124,9 @@ func (pi * PixelInterface) RunPlayerListener(receivingAddr string) {
 		buf := make([]byte, 1024)
 		rlen, err := player.Read(buf)
 		if err != nil {
+			if pi.isClosed() {
+				return
+			}
 			log.Fatal("Pixel node disconnected")
 		} else if string(buf[0:rlen]) == "getgameconfig"{
 			SendGameConfig(pi, player)
@@ -126,6 +143,9 @@ func (pi * PixelInterface) GetTCPConn() (*net.TCPConn) {
 	player := pi.pixelListener
 	conn, err := player.AcceptTCP()
 	if err != nil {
+		if pi.isClosed() {
+			return nil
+		}
 		fmt.Println(err)
 	}
 	SendGameConfig(pi, conn)
@@ -141,3 +161,27 @@ func SendGameConfig(pi *PixelInterface, conn *net.TCPConn) {
 		conn.Write(marshalledConfig)
 	}
 }
+
+// Stops listening to the pixel node and closes the connection to it
+func (pi *PixelInterface) Close() {
+	if pi.quit == nil || pi.isClosed() {
+		return
+	}
+	close(pi.quit)
+	if pi.pixelListener != nil {
+		pi.pixelListener.Close()
+	}
+	if pi.pixelWriter != nil {
+		pi.pixelWriter.Close()
+	}
+}
+
+// Returns true once Close() has been called
+func (pi *PixelInterface) isClosed() bool {
+	select {
+	case <-pi.quit:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,163 @@
+package impl
+
+import (
+	"fmt"
+	"sync"
+	"../../shared"
+)
+
+// This node's own moves that have been applied and rendered ahead of being committed. If one of them fails it is rolled
+// back, and the inputs that came after it are replayed from where the wolf was before it.
+type Prediction struct {
+	sync.Mutex
+
+	// Moves that have not been committed yet, oldest first
+	unconfirmed []predictedMove
+
+	// Where the last committed move left this node's wolf, or where it started
+	confirmed   shared.Coord
+}
+
+type predictedMove struct {
+	seq    uint64
+	coord  shared.Coord
+	// The player inputs ("up", "left", ...) the move was made from. A move that corrects the position after a rollback
+	// stands for every input it replayed.
+	inputs []string
+}
+
+// Creates an empty prediction for a wolf that starts at start
+func NewPrediction(start shared.Coord) *Prediction {
+	return &Prediction{confirmed: start}
+}
+
+// Remembers this node's move to coord at seq, made from inputs, until it is committed or fails. Must be called with
+// the lock held.
+func (p *Prediction) Predict(seq uint64, coord shared.Coord, inputs []string) {
+	p.unconfirmed = append(p.unconfirmed, predictedMove{seq, coord, inputs})
+}
+
+// Returns the sequence numbers of the moves not yet committed, oldest first. Must be called with the lock held.
+func (p *Prediction) Unconfirmed() []uint64 {
+	seqs := make([]uint64, len(p.unconfirmed))
+	for i, move := range p.unconfirmed {
+		seqs[i] = move.seq
+	}
+	return seqs
+}
+
+// Returns where the last move still being predicted puts this node's wolf, or where the last committed move left it if
+// there is none. Must be called with the lock held.
+func (p *Prediction) Last() shared.Coord {
+	if len(p.unconfirmed) == 0 {
+		return p.confirmed
+	}
+	return p.unconfirmed[len(p.unconfirmed)-1].coord
+}
+
+// Must be called with the lock held
+func (p *Prediction) find(seq uint64) int {
+	for i, move := range p.unconfirmed {
+		if move.seq == seq {
+			return i
+		}
+	}
+	return -1
+}
+
+// Handles this node's move at seq being committed. Moves before it are settled along with it, since it was made on
+// top of them and every node now has this node's wolf where it put it. Must be called with the lock held.
+// Returns false if the move is not one still being predicted, such as a move replaced by a rollback
+func (p *Prediction) Commit(seq uint64) bool {
+	i := p.find(seq)
+	if i < 0 {
+		return false
+	}
+	p.confirmed = p.unconfirmed[i].coord
+	p.unconfirmed = p.unconfirmed[i+1:]
+	return true
+}
+
+// Rolls back this node's move at seq, and every move after it, which were made on top of it. The inputs of the moves
+// after it are replayed with step, starting from where the wolf was before the failed move; inputs step does not
+// allow any more are dropped. If the replay ends where the wolf already is, there is nothing to put right: only the
+// failed move is dropped, and any moves after it stay predicted. Must be called with the lock held.
+// Returns where the replay leaves the wolf and the inputs that got it there, to be sent as a new move; or false if
+// there is nothing to send, as the move is not one still being predicted or the wolf ends up where it already is
+func (p *Prediction) Fail(seq uint64,
+	step func(from shared.Coord, input string) (shared.Coord, bool)) (shared.Coord, []string, bool) {
+	i := p.find(seq)
+	if i < 0 {
+		return shared.Coord{}, nil, false
+	}
+	pos := p.confirmed
+	if i > 0 {
+		pos = p.unconfirmed[i-1].coord
+	}
+	replayed := []string{}
+	for _, move := range p.unconfirmed[i+1:] {
+		for _, input := range move.inputs {
+			if next, ok := step(pos, input); ok {
+				pos = next
+				replayed = append(replayed, input)
+			}
+		}
+	}
+	if pos == p.Last() {
+		p.unconfirmed = append(p.unconfirmed[:i], p.unconfirmed[i+1:]...)
+		return pos, nil, false
+	}
+	p.unconfirmed = p.unconfirmed[:i]
+	return pos, replayed, true
+}
+
+// Sends this node's move to move, made from inputs, and applies and renders it straight away rather than waiting for
+// it to be committed
+func (pn *PlayerNode) sendPredictedMove(move shared.Coord, inputs []string) {
+	pn.predicted.Lock()
+	pn.sendPredictedMoveLocked(move, inputs)
+	pn.predicted.Unlock()
+	pn.nodeInterface.GameStateToSend <- true
+}
+
+// Must be called with the prediction's lock held, which also keeps the move's sequence number and its place in the
+// prediction in step with the moves sent on a rollback
+func (pn *PlayerNode) sendPredictedMoveLocked(move shared.Coord, inputs []string) {
+	seq := pn.nodeInterface.SendMoveToNodes(&move)
+	pn.predicted.Predict(seq, move, inputs)
+	pn.nodeInterface.ApplyOwnMove(move, seq)
+}
+
+// Handles one of this node's moves settling. The move was already applied when it was sent, so a committed move just
+// stops being predicted. A failed one is rolled back: the inputs after it are replayed from where the wolf was before
+// it, and the position that leaves it at is sent as a new move, so the nodes that took the failed move are put right
+// too. Either way the pixel node is sent the corrected gamestate. Must be called from the game loop, which is the only
+// place this node's moves are sent from.
+func (pn *PlayerNode) MoveSettled(move SettledMove) {
+	pn.predicted.Lock()
+	switch move.Outcome {
+	case MoveCommitted:
+		pn.predicted.Commit(move.Seq)
+	case MoveFailed:
+		pos, replayed, ok := pn.predicted.Fail(move.Seq, pn.nextPosition)
+		if ok {
+			fmt.Printf("Move %d to %v failed, replaying %d inputs from there\n", move.Seq, move.Coord, len(replayed))
+			pn.sendPredictedMoveLocked(pos, replayed)
+		}
+	}
+	pn.predicted.Unlock()
+	pn.nodeInterface.GameStateToSend <- true
+}
+
+// Handles every one of this node's moves that has settled since the last call, without waiting for more. For game
+// loops that do not wait on MovesSettled themselves.
+func (pn *PlayerNode) settleMoves() {
+	for {
+		select {
+		case move := <-pn.nodeInterface.MovesSettled:
+			pn.MoveSettled(move)
+		default:
+			return
+		}
+	}
+}
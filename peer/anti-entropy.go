@@ -0,0 +1,154 @@
+package peer
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"strconv"
+	"time"
+	"../shared"
+)
+
+// How often nodes swap state digests when the config does not set DigestInterval
+const DEFAULT_DIGEST_INTERVAL = 5 * time.Second
+
+func (n *Node) digestInterval() time.Duration {
+	if n.Config.DigestInterval == 0 {
+		return DEFAULT_DIGEST_INTERVAL
+	}
+	return time.Duration(n.Config.DigestInterval) * time.Millisecond
+}
+
+// Returns a compact digest of this node's gamestate: for each player, a hash of its position, the sequence number
+// of the move that put it there, and its score
+func (n *Node) StateDigest() map[string]uint64 {
+	digest := make(map[string]uint64)
+	gameState := n.Role.GameState()
+	if gameState == nil {
+		return digest
+	}
+	gameState.PlayerLocs.RLock()
+	gameState.PlayerScores.RLock()
+	defer gameState.PlayerLocs.RUnlock()
+	defer gameState.PlayerScores.RUnlock()
+	for id := range gameState.PlayerLocs.Data {
+		digest[id] = entityHash(id, gameState)
+	}
+	for id := range gameState.PlayerScores.Data {
+		digest[id] = entityHash(id, gameState)
+	}
+	return digest
+}
+
+// Must be called with the locks on gameState held
+func entityHash(id string, gameState *shared.GameState) uint64 {
+	arr := strconv.AppendQuote(nil, id)
+	if pos, ok := gameState.PlayerLocs.Data[id]; ok {
+		arr = strconv.AppendInt(arr, int64(pos.X), 10)
+		arr = append(arr, ',')
+		arr = strconv.AppendInt(arr, int64(pos.Y), 10)
+		arr = append(arr, '@')
+		arr = strconv.AppendUint(arr, gameState.PlayerLocs.Seq[id], 10)
+	}
+	arr = append(arr, ';')
+	if score, ok := gameState.PlayerScores.Data[id]; ok {
+		arr = strconv.AppendInt(arr, int64(score), 10)
+	}
+	hash := sha256.Sum256(arr)
+	return binary.BigEndian.Uint64(hash[:8])
+}
+
+// Routine that sends this node's state digest to every other node every DigestInterval. Lost moves and captures
+// otherwise leave nodes disagreeing for the rest of the game.
+func (n *Node) RunAntiEntropy() {
+	ticker := time.NewTicker(n.digestInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			n.SendStateDigest()
+		case <-n.Done():
+			return
+		}
+	}
+}
+
+// Sends this node's state digest to every other node
+func (n *Node) SendStateDigest() {
+	message := NodeMessage{
+		MessageType: shared.StateDigestMessage,
+		Identifier:  n.Role.Identifier(),
+		Digest:      n.StateDigest(),
+		Addr:        n.LocalAddr.String(),
+	}
+	n.Send("all", message, "Sendin' state digest")
+}
+
+// Returns the players whose state differs between this node's digest and theirs, including the ones only this node
+// has. Players only the other node has are left out; it sends those to us when it gets our digest.
+func (n *Node) DifferingEntities(theirs map[string]uint64) []string {
+	var differ []string
+	for id, hash := range n.StateDigest() {
+		if other, ok := theirs[id]; !ok || other != hash {
+			differ = append(differ, id)
+		}
+	}
+	return differ
+}
+
+// Handles another node's state digest by sending it our state for just the players that differ
+func (n *Node) HandleReceivedStateDigest(identifier string, digest map[string]uint64) {
+	differ := n.DifferingEntities(digest)
+	if len(differ) == 0 {
+		return
+	}
+	message := NodeMessage{
+		MessageType: shared.StateRepairMessage,
+		Identifier:  n.Role.Identifier(),
+		Snapshot:    n.PartialGameState(differ),
+		Addr:        n.LocalAddr.String(),
+	}
+	n.Send(identifier, message, "Sendin' state repair")
+}
+
+// Returns a snapshot holding only the given players from this node's gamestate
+func (n *Node) PartialGameState(ids []string) *shared.StateSnapshot {
+	current := n.copyState()
+	partial := &shared.StateSnapshot{
+		Locs:   make(map[string]shared.Coord),
+		Seqs:   make(map[string]uint64),
+		Scores: make(map[string]int),
+	}
+	for _, id := range ids {
+		if pos, ok := current.Locs[id]; ok {
+			partial.Locs[id] = pos
+			partial.Seqs[id] = current.Seqs[id]
+		}
+		if score, ok := current.Scores[id]; ok {
+			partial.Scores[id] = score
+		}
+	}
+	return partial
+}
+
+// Handles another node's state for the players our digests disagreed on. Positions are merged by sequence number as
+// for a joining node's gamestate. Scores come from the capture ledger, so a score that differs is not copied;
+// instead the ledgers are reconciled, which brings the scores into line.
+func (n *Node) HandleReceivedStateRepair(identifier string, snapshot *shared.StateSnapshot) {
+	if snapshot == nil || !n.mergeSnapshot(snapshot, false) {
+		return
+	}
+	ours := n.Role.GameState()
+	ours.PlayerScores.RLock()
+	scoresDiffer := false
+	for id, score := range snapshot.Scores {
+		if ours.PlayerScores.Data[id] != score {
+			scoresDiffer = true
+		}
+	}
+	ours.PlayerScores.RUnlock()
+
+	n.Role.GameStateChanged()
+	if scoresDiffer {
+		n.SendLedgerDigest(identifier)
+	}
+}
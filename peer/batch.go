@@ -0,0 +1,98 @@
+package peer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// Datagrams that start with this are a batch of messages rather than a single message. A batch is the magic followed
+// by each message, prefixed with its length as a big endian uint16.
+var batchMagic = []byte("WBAT")
+
+// The largest batch that is sent, small enough to fit in a single ethernet frame. Messages that do not fit in an empty
+// batch are sent on their own.
+const MAX_BATCH_BYTES = 1400
+
+// Messages waiting to be sent to each node as one batch. Only used by ManageOtherNodes, so there is no lock.
+type outbox struct {
+	// The batch being built for each node
+	pending map[string]*bytes.Buffer
+
+	// Fires when the pending batches should be sent; nil while there are none
+	flush   <-chan time.Time
+}
+
+// Writes message to the node id, or adds it to the node's batch if batching is on. Only called by ManageOtherNodes.
+func (n *Node) writeTo(id string, message []byte) {
+	window := time.Duration(n.Config.BatchWindow) * time.Millisecond
+	if window <= 0 || len(batchMagic)+2+len(message) > MAX_BATCH_BYTES {
+		n.writeNow(id, message)
+		return
+	}
+
+	if n.outbox.pending == nil {
+		n.outbox.pending = make(map[string]*bytes.Buffer)
+	}
+	batch, ok := n.outbox.pending[id]
+	if ok && batch.Len()+2+len(message) > MAX_BATCH_BYTES {
+		n.writeNow(id, batch.Bytes())
+		ok = false
+	}
+	if !ok {
+		batch = bytes.NewBuffer(append([]byte(nil), batchMagic...))
+		n.outbox.pending[id] = batch
+	}
+	binary.Write(batch, binary.BigEndian, uint16(len(message)))
+	batch.Write(message)
+
+	if n.outbox.flush == nil {
+		n.outbox.flush = time.After(window)
+	}
+}
+
+// Sends every pending batch. Only called by ManageOtherNodes.
+func (n *Node) flushBatches() {
+	for id, batch := range n.outbox.pending {
+		n.writeNow(id, batch.Bytes())
+		delete(n.outbox.pending, id)
+	}
+	n.outbox.flush = nil
+}
+
+func (n *Node) writeNow(id string, message []byte) {
+	conn, ok := n.OtherNodes[id]
+	if !ok {
+		return
+	}
+	_, err := conn.Write(message)
+	if err != nil {
+		fmt.Println(err)
+		n.NodesWriteConnRefused <- id
+	}
+}
+
+// Splits a received datagram into the messages in it. A datagram that is not a batch is a single message.
+// Returns nil if the batch is malformed.
+func unpackBatch(datagram []byte) [][]byte {
+	if !bytes.HasPrefix(datagram, batchMagic) {
+		return [][]byte{datagram}
+	}
+
+	var messages [][]byte
+	rest := datagram[len(batchMagic):]
+	for len(rest) > 0 {
+		if len(rest) < 2 {
+			return nil
+		}
+		length := int(binary.BigEndian.Uint16(rest))
+		rest = rest[2:]
+		if length > len(rest) {
+			return nil
+		}
+		messages = append(messages, rest[:length])
+		rest = rest[length:]
+	}
+	return messages
+}
@@ -0,0 +1,274 @@
+package peer
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"../wolferrors"
+	"../shared"
+)
+
+// How long a wolf waits for the other nodes to confirm its capture before giving up on it
+const CAPTURE_CONFIRM_TIMEOUT = time.Second
+
+// How long after a wolf moved onto the prey's cell its capture there can still be confirmed, since it may have moved
+// on by the time its capture arrives
+const CAPTURER_POSITION_SLACK = 2 * time.Second
+
+// This node's captures that are waiting on confirmations, by CaptureId
+type pendingCaptures struct {
+	sync.Mutex
+	byId map[string]*pendingCapture
+}
+
+type pendingCapture struct {
+	event         shared.CaptureEvent
+	confirmations map[string]shared.CaptureConfirmation
+	proposed      time.Time
+}
+
+// Returns the number of confirmations a capture needs when the capturer knew of peers other nodes: a majority of them
+func CaptureQuorum(peers int) int {
+	return peers/2 + 1
+}
+
+// Returns the bytes a node signs to confirm event. They are tagged so a confirmation can never pass for the
+// capturer's own signature.
+func (n *Node) confirmationBytes(event *shared.CaptureEvent) []byte {
+	arr := strconv.AppendQuote(nil, "confirm")
+	return append(arr, n.captureEventBytes(event)...)
+}
+
+// Starts a capture of prey at preyPos, which it was at for preySeq, by this node. The capture only counts once a
+// quorum of the other nodes, including that prey, have confirmed it; see HandleReceivedCaptureConfirmation. With
+// DeterministicPrey, the prey's signed move is not needed if this node worked out it was at preyPos, and the prey
+// need not be among those confirming.
+// Returns the signed capture to send to the other nodes, InvalidPreyCaptureError if this node does not have the
+// prey's signed move to preyPos or already captured the prey at preySeq, or CaptureTieLostError if another wolf
+// already holds the capture at preySeq
+func (n *Node) ProposeCapture(prey string, preyPos shared.Coord, preySeq uint64) (*shared.CaptureEvent, error) {
+	preyMove, ok := n.Ledger.preyMove(prey, preySeq)
+	if !ok && n.DeterministicPrey() {
+		pos, worked := n.RW.PositionAt(prey, preySeq)
+		ok = worked && pos == preyPos
+	}
+	if !ok {
+		return nil, wolferrors.InvalidPreyCaptureError(preyKey(prey, preySeq))
+	}
+	self := n.Role.Identifier()
+	if holder, ok := n.Ledger.Holder(prey, preySeq); ok && CaptureTieBreak(preySeq, self, holder.Capturer) != self {
+		return nil, wolferrors.CaptureTieLostError(holder.Capturer)
+	}
+	event := &shared.CaptureEvent{
+		Capturer: self,
+		Prey:     prey,
+		PreyPos:  preyPos,
+		PreySeq:  preySeq,
+		Worth:    n.Config.InitState.CatchWorth,
+		Peers:    n.OtherNodeCount(),
+		PreyMove: preyMove,
+	}
+	id := CaptureId(event)
+
+	n.pending.Lock()
+	defer n.pending.Unlock()
+	if n.pending.byId == nil {
+		n.pending.byId = make(map[string]*pendingCapture)
+	}
+	for pendingId, p := range n.pending.byId {
+		if time.Since(p.proposed) > CAPTURE_CONFIRM_TIMEOUT {
+			delete(n.pending.byId, pendingId)
+		}
+	}
+	if _, ok := n.pending.byId[id]; ok {
+		return nil, wolferrors.InvalidPreyCaptureError(id)
+	}
+	if n.Ledger.Has(id) {
+		return nil, wolferrors.InvalidPreyCaptureError(id)
+	}
+
+	signature := n.SignBytes(n.captureEventBytes(event))
+	event.R, event.S = signature.R, signature.S
+	n.pending.byId[id] = &pendingCapture{
+		event:         *event,
+		confirmations: make(map[string]shared.CaptureConfirmation),
+		proposed:      time.Now(),
+	}
+	return event, nil
+}
+
+// Checks another wolf's capture and confirms it if it holds up: as well as the capture itself checking out (see
+// VerifyCaptureEvent), this node must have seen the capturer on the prey's cell. A wolf can not make its capture
+// easier to certify by claiming to know of fewer nodes than this one does, less one for a node joining while the
+// capture was going on.
+// Returns this node's signed confirmation, InvalidPreyCaptureError if the capture does not hold up, or
+// CapturePeersError if the capturer knew of too few nodes
+func (n *Node) ConfirmCapture(event *shared.CaptureEvent) (*shared.CaptureConfirmation, error) {
+	err := n.VerifyCaptureEvent(event)
+	if err != nil {
+		return nil, err
+	}
+	self := n.Role.Identifier()
+	if event.Capturer == self || !n.recentlyAt(event.Capturer, event.PreyPos) {
+		return nil, wolferrors.InvalidPreyCaptureError(CaptureId(event))
+	}
+	if event.Peers + 1 < n.OtherNodeCount() {
+		return nil, wolferrors.CapturePeersError(CaptureId(event))
+	}
+	signature := n.SignBytes(n.confirmationBytes(event))
+	return &shared.CaptureConfirmation{Identifier: self, R: signature.R, S: signature.S}, nil
+}
+
+// Handles a "captured" message: the capture is confirmed back to the capturer if it holds up, and rejected otherwise.
+// A capture that does not hold up is recorded as evidence of the capturer cheating; one rejected because nodes joined
+// while it was going on is not, since that can happen to an honest wolf.
+func (n *Node) HandleCaptureRequest(identifier string, event *shared.CaptureEvent) {
+	if event == nil || event.Capturer != identifier {
+		fmt.Println("Ignoring capture that was not sent by its capturer", identifier)
+		return
+	}
+	confirmation, err := n.ConfirmCapture(event)
+	if err != nil {
+		fmt.Println("rejecting capturing prey", err)
+		if _, ok := err.(wolferrors.InvalidPreyCaptureError); ok {
+			n.RecordCheat(identifier, ScoreCheat, event.PreySeq, fmt.Sprintf("capture of %s at %v", event.Prey,
+					event.PreyPos))
+		}
+		n.SendPreyCaptureReject(identifier, event)
+		return
+	}
+	message := NodeMessage{
+		MessageType:  shared.ConfirmMessage,
+		Identifier:   n.Role.Identifier(),
+		Prey:         event.Prey,
+		PreySeq:      event.PreySeq,
+		Confirmation: confirmation,
+		Addr:         n.LocalAddr.String(),
+	}
+	n.Send(identifier, message, "Sendin' capture confirmation")
+}
+
+// Returns true if this node has identifier on pos, or saw it move there within the last CAPTURER_POSITION_SLACK
+func (n *Node) recentlyAt(identifier string, pos shared.Coord) bool {
+	if gameState := n.Role.GameState(); gameState != nil {
+		gameState.PlayerLocs.RLock()
+		loc, ok := gameState.PlayerLocs.Data[identifier]
+		gameState.PlayerLocs.RUnlock()
+		if ok && loc == pos {
+			return true
+		}
+	}
+	for _, move := range n.RW.Within(identifier, CAPTURER_POSITION_SLACK) {
+		if move.Pos == pos {
+			return true
+		}
+	}
+	return false
+}
+
+// Tells the capturer toSendID that its capture did not hold up here
+func (n *Node) SendPreyCaptureReject(toSendID string, event *shared.CaptureEvent) {
+	message := NodeMessage{
+		MessageType: shared.RejectedMessage,
+		Identifier:  n.Role.Identifier(),
+		Capture:     event,
+		Seq:         n.SequenceNumber,
+		Prey:        event.Prey,
+		PreySeq:     event.PreySeq,
+		Addr:        n.LocalAddr.String(),
+	}
+	n.Send(toSendID, message, "Sendin' rejectin' capture")
+}
+
+// Handles identifier's confirmation of this node's capture of prey at preySeq. Once a quorum including that prey
+// have confirmed it, the capture certificate is added to the ledger and sent to every other node.
+// Returns the certificate if this confirmation completed it, nil otherwise
+func (n *Node) HandleReceivedCaptureConfirmation(identifier string, prey string, preySeq uint64,
+	confirmation *shared.CaptureConfirmation) *shared.CaptureEvent {
+	if confirmation == nil || confirmation.Identifier != identifier {
+		return nil
+	}
+	id := captureId(n.Role.Identifier(), prey, preySeq)
+
+	n.pending.Lock()
+	p, ok := n.pending.byId[id]
+	if !ok || time.Since(p.proposed) > CAPTURE_CONFIRM_TIMEOUT {
+		delete(n.pending.byId, id)
+		n.pending.Unlock()
+		return nil
+	}
+	if !n.checkConfirmation(&p.event, confirmation) {
+		n.pending.Unlock()
+		fmt.Println("Ignoring capture confirmation that was not signed by", identifier)
+		return nil
+	}
+	p.confirmations[identifier] = *confirmation
+	_, preyConfirmed := p.confirmations[prey]
+	if !(preyConfirmed || n.DeterministicPrey()) || len(p.confirmations) < CaptureQuorum(p.event.Peers) {
+		n.pending.Unlock()
+		return nil
+	}
+	certificate := p.event
+	for _, c := range p.confirmations {
+		certificate.Confirmations = append(certificate.Confirmations, c)
+	}
+	sort.Slice(certificate.Confirmations, func(i, j int) bool {
+		return certificate.Confirmations[i].Identifier < certificate.Confirmations[j].Identifier
+	})
+	delete(n.pending.byId, id)
+	n.pending.Unlock()
+
+	err := n.CheckCapture(&certificate)
+	if err != nil {
+		fmt.Println("Could not certify capture:", err)
+		return nil
+	}
+	message := NodeMessage{
+		MessageType: shared.CaptureCertMessage,
+		Identifier:  n.Role.Identifier(),
+		Capture:     &certificate,
+		Prey:        prey,
+		PreySeq:     preySeq,
+		Addr:        n.LocalAddr.String(),
+	}
+	n.Send("all", message, "Sendin' capture certificate")
+	return &certificate
+}
+
+// Returns true if confirmation of event was signed by the node it names, using the public key stored for it. A
+// confirmation from a node whose key this node does not have can not be checked, so it does not count.
+func (n *Node) checkConfirmation(event *shared.CaptureEvent, confirmation *shared.CaptureConfirmation) bool {
+	if confirmation.Identifier == event.Capturer {
+		return false
+	}
+	publicKey := n.keyOf(confirmation.Identifier)
+	if publicKey == nil {
+		return false
+	}
+	signature := shared.SignedMove{MoveByte: n.confirmationBytes(event), R: confirmation.R, S: confirmation.S}
+	return n.CheckAuthenticityOfMove(publicKey, &signature)
+}
+
+// Checks a capture certificate: the capture itself must hold up (see VerifyCaptureEvent) and carry confirmations
+// from a quorum of the nodes the capturer knew of, one of them the captured prey unless prey moves are worked out by
+// every node (DeterministicPrey).
+// Returns InvalidPreyCaptureError if the capture does not hold up, or UncertifiedCaptureError if there are not enough
+// confirmations that check out
+func (n *Node) VerifyCertificate(event *shared.CaptureEvent) error {
+	err := n.verifyCaptureEvent(event, false)
+	if err != nil {
+		return err
+	}
+	confirmed := make(map[string]bool)
+	for i := range event.Confirmations {
+		if n.checkConfirmation(event, &event.Confirmations[i]) {
+			confirmed[event.Confirmations[i].Identifier] = true
+		}
+	}
+	if !(confirmed[event.Prey] || n.DeterministicPrey()) || len(confirmed) < CaptureQuorum(event.Peers) {
+		return wolferrors.UncertifiedCaptureError(CaptureId(event))
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package peer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// The number of pieces of evidence kept for each player
+const EVIDENCE_TO_KEEP = 20
+
+// The kinds of cheating a node can record evidence of
+type CheatKind string
+
+const (
+	// Moving faster than MoveInterval allows
+	SpeedCheat        CheatKind = "speed"
+	// Moving further than one cell per move
+	TeleportCheat     CheatKind = "teleport"
+	// Moving off the board or into a wall
+	InvalidMoveCheat  CheatKind = "invalid move"
+	// Sending a move that was not signed with the player's key
+	BadSignatureCheat CheatKind = "bad signature"
+	// Claiming a capture that does not hold up, to inflate its score
+	ScoreCheat        CheatKind = "score"
+	// A prey taking a step other than the one worked out for it with DeterministicPrey
+	PreyStepCheat     CheatKind = "prey step"
+)
+
+// Something this node saw a player do that breaks the rules
+type CheatEvidence struct {
+	Identifier string
+	Kind       CheatKind
+	// The sequence number of the move that broke the rules
+	Seq        uint64
+	Detail     string
+	// When this node saw it, by its own clock
+	Observed   time.Time
+}
+
+// Evidence of cheating this node has seen, by player
+type cheatLog struct {
+	sync.Mutex
+	byPlayer map[string][]CheatEvidence
+}
+
+// Records evidence that identifier cheated with its move at seq. Once there are CHEATS_TO_ACCUSE pieces of evidence
+// against a player, this node votes to evict it (see Accuse).
+func (n *Node) RecordCheat(identifier string, kind CheatKind, seq uint64, detail string) {
+	fmt.Printf("Cheat by %s at sequence %d (%s): %s\n", identifier, seq, kind, detail)
+	n.cheats.Lock()
+	if n.cheats.byPlayer == nil {
+		n.cheats.byPlayer = make(map[string][]CheatEvidence)
+	}
+	evidence := append(n.cheats.byPlayer[identifier], CheatEvidence{
+		Identifier: identifier,
+		Kind:       kind,
+		Seq:        seq,
+		Detail:     detail,
+		Observed:   time.Now(),
+	})
+	if len(evidence) > EVIDENCE_TO_KEEP {
+		evidence = evidence[len(evidence)-EVIDENCE_TO_KEEP:]
+	}
+	n.cheats.byPlayer[identifier] = evidence
+	n.cheats.Unlock()
+
+	if len(evidence) >= CHEATS_TO_ACCUSE {
+		n.Accuse(identifier)
+	}
+}
+
+// Returns the evidence of cheating this node has recorded against identifier, oldest first
+func (n *Node) Evidence(identifier string) []CheatEvidence {
+	n.cheats.Lock()
+	defer n.cheats.Unlock()
+	evidence := make([]CheatEvidence, len(n.cheats.byPlayer[identifier]))
+	copy(evidence, n.cheats.byPlayer[identifier])
+	return evidence
+}
@@ -0,0 +1,63 @@
+package peer
+
+import (
+	"log"
+	"time"
+)
+
+// How often a node logs its drop counters (see ReportDiagnostics)
+const DIAGNOSTICS_INTERVAL = 30 * time.Second
+
+// A snapshot of the counters a node keeps about the messages it has dropped
+type Diagnostics struct {
+	// Messages of a kind with no registered handler
+	UnknownMessages uint64
+
+	// Messages dropped for being over their sender's budget, by class
+	RateLimited     map[MessageClass]uint64
+
+	// Messages dropped because their sender was muted
+	MutedDrops      uint64
+
+	// The addresses that are muted right now
+	Muted           []string
+
+	// Times another node's game state did not match ours after the same tick
+	Divergences     uint64
+}
+
+// Returns a snapshot of this node's drop counters
+func (n *Node) Diagnostics() Diagnostics {
+	diagnostics := Diagnostics{UnknownMessages: n.UnknownMessageCount(), Divergences: n.Divergences()}
+	if n.Limiter != nil {
+		diagnostics.RateLimited = n.Limiter.Dropped()
+		diagnostics.MutedDrops = n.Limiter.MutedDrops()
+		diagnostics.Muted = n.Limiter.Muted()
+	}
+	return diagnostics
+}
+
+// Returns true if nothing has been dropped and nobody is muted
+func (d Diagnostics) quiet() bool {
+	dropped := d.UnknownMessages + d.MutedDrops + d.Divergences
+	for _, count := range d.RateLimited {
+		dropped += count
+	}
+	return dropped == 0 && len(d.Muted) == 0
+}
+
+// Routine that logs this node's drop counters every DIAGNOSTICS_INTERVAL, once there is anything in them
+func (n *Node) ReportDiagnostics() {
+	ticker := time.NewTicker(DIAGNOSTICS_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if diagnostics := n.Diagnostics(); !diagnostics.quiet() {
+				log.Printf("Diagnostics: %+v\n", diagnostics)
+			}
+		case <-n.Done():
+			return
+		}
+	}
+}
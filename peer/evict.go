@@ -0,0 +1,183 @@
+package peer
+
+import (
+	"fmt"
+	"sync"
+	"../shared"
+	key "../key-helpers"
+)
+
+// The pieces of evidence this node must have against a player before it votes to evict it
+const CHEATS_TO_ACCUSE = 3
+
+// Accusations this node has seen, and the players they got evicted
+type evictions struct {
+	sync.Mutex
+
+	// Accusations by accused, then by witness
+	accusations map[string]map[string]shared.Accusation
+
+	// Players evicted from the game. Their messages are dropped and they can not connect again.
+	evicted     map[string]bool
+}
+
+// Returns the number of accusations it takes to evict a player when voters nodes, not counting the player, get a
+// say: a majority of them, as for a capture
+func EvictionQuorum(voters int) int {
+	return CaptureQuorum(voters)
+}
+
+// Returns true if identifier was evicted from the game for cheating
+func (n *Node) Evicted(identifier string) bool {
+	n.evictions.Lock()
+	defer n.evictions.Unlock()
+	return n.evictions.evicted[identifier]
+}
+
+// Returns the accusations this node has seen against identifier
+func (n *Node) Accusations(identifier string) []shared.Accusation {
+	n.evictions.Lock()
+	defer n.evictions.Unlock()
+	var accusations []shared.Accusation
+	for _, accusation := range n.evictions.accusations[identifier] {
+		accusations = append(accusations, accusation)
+	}
+	return accusations
+}
+
+// Votes to evict identifier by sending every other node an accusation, signed by this node, carrying the evidence
+// this node has recorded against it. A node only accuses a player once.
+func (n *Node) Accuse(identifier string) {
+	self := n.Role.Identifier()
+	accusation := shared.Accusation{
+		Witness:  self,
+		Accused:  identifier,
+		Evidence: []string{},
+	}
+	if publicKey := n.keyOf(identifier); publicKey != nil {
+		accusation.AccusedKey = key.PubKeyToString(*publicKey)
+	}
+	for _, evidence := range n.Evidence(identifier) {
+		accusation.Evidence = append(accusation.Evidence,
+			fmt.Sprintf("%s at sequence %d: %s", evidence.Kind, evidence.Seq, evidence.Detail))
+	}
+	signature := n.SignBytes(shared.AccusationBytes(accusation))
+	accusation.R, accusation.S = signature.R, signature.S
+	if !n.addAccusation(accusation) {
+		return
+	}
+
+	fmt.Println("Accusing", identifier, "of cheating")
+	message := NodeMessage{
+		MessageType: shared.AccusationMessage,
+		Identifier:  self,
+		Accusations: []shared.Accusation{accusation},
+		Addr:        n.LocalAddr.String(),
+	}
+	n.Send("all", message, "Sendin' accusation")
+	n.checkEviction(identifier)
+}
+
+// Adds accusation to the ones seen against its accused.
+// Returns false if the witness had already accused the player, or the player was already evicted
+func (n *Node) addAccusation(accusation shared.Accusation) bool {
+	n.evictions.Lock()
+	defer n.evictions.Unlock()
+	if n.evictions.evicted[accusation.Accused] {
+		return false
+	}
+	if n.evictions.accusations == nil {
+		n.evictions.accusations = make(map[string]map[string]shared.Accusation)
+	}
+	byWitness, ok := n.evictions.accusations[accusation.Accused]
+	if !ok {
+		byWitness = make(map[string]shared.Accusation)
+		n.evictions.accusations[accusation.Accused] = byWitness
+	}
+	if _, ok := byWitness[accusation.Witness]; ok {
+		return false
+	}
+	byWitness[accusation.Witness] = accusation
+	return true
+}
+
+// Returns true if accusation was signed by its witness, using the public key stored for it. Accusations from nodes
+// whose keys this node does not have can not be checked, so they do not count.
+func (n *Node) checkAccusation(accusation *shared.Accusation) bool {
+	if accusation.Witness == accusation.Accused {
+		return false
+	}
+	publicKey := n.keyOf(accusation.Witness)
+	if publicKey == nil {
+		return false
+	}
+	signature := shared.SignedMove{MoveByte: shared.AccusationBytes(*accusation), R: accusation.R, S: accusation.S}
+	return n.CheckAuthenticityOfMove(publicKey, &signature)
+}
+
+// Handles accusations sent by identifier. They need not be its own: a node that evicts a player passes on every
+// accusation it had, so nodes that missed some still reach the quorum.
+func (n *Node) HandleReceivedAccusations(identifier string, accusations []shared.Accusation) {
+	self := n.Role.Identifier()
+	for i := range accusations {
+		accusation := accusations[i]
+		if accusation.Accused == self {
+			fmt.Println("Accused of cheating by", accusation.Witness, accusation.Evidence)
+			continue
+		}
+		if !n.checkAccusation(&accusation) {
+			fmt.Println("Ignoring accusation from", identifier, "that was not signed by", accusation.Witness)
+			continue
+		}
+		if n.addAccusation(accusation) {
+			n.checkEviction(accusation.Accused)
+		}
+	}
+}
+
+// Evicts identifier once a quorum of the nodes this node knows of, other than identifier, have accused it
+func (n *Node) checkEviction(identifier string) {
+	voters := n.OtherNodeCount() + 1
+	if n.IsOtherNode(identifier) {
+		voters--
+	}
+	accusations := n.Accusations(identifier)
+	if len(accusations) < EvictionQuorum(voters) {
+		return
+	}
+
+	n.evictions.Lock()
+	if n.evictions.evicted == nil {
+		n.evictions.evicted = make(map[string]bool)
+	}
+	n.evictions.evicted[identifier] = true
+	n.evictions.Unlock()
+
+	fmt.Printf("Evicting %s, accused of cheating by %d nodes\n", identifier, len(accusations))
+	n.NodesToDelete <- identifier
+	message := NodeMessage{
+		MessageType: shared.AccusationMessage,
+		Identifier:  n.Role.Identifier(),
+		Accusations: accusations,
+		Addr:        n.LocalAddr.String(),
+	}
+	n.Send("all", message, "Sendin' accusations")
+
+	if n.ServerConn != nil {
+		report := shared.EvictionReport{
+			Accused:     identifier,
+			AccusedKey:  accusations[0].AccusedKey,
+			Accusations: accusations,
+		}
+		go n.reportEviction(report)
+	}
+}
+
+// Reports an evicted player to the server, which bans its key
+func (n *Node) reportEviction(report shared.EvictionReport) {
+	var _ignored bool
+	err := n.ServerConn.Call("GServer.ReportCheater", report, &_ignored)
+	if err != nil {
+		fmt.Printf("DEBUG - ReportCheater err: [%s]\n", err)
+	}
+}
@@ -0,0 +1,139 @@
+package peer
+
+import (
+	"sync"
+	"time"
+	"../shared"
+)
+
+// What a node last told the nodes outside its interest radius about where it is
+type positionSummaries struct {
+	sync.Mutex
+
+	// Where this node last moved to, or nil if it has not moved yet
+	last *shared.Coord
+
+	// The position last summarised to each far node
+	sent map[string]shared.Coord
+}
+
+func (s *positionSummaries) moved(move shared.Coord) {
+	s.Lock()
+	s.last = &move
+	s.Unlock()
+}
+
+// Returns the identifiers of the other nodes that are further than the interest radius away from pos. These nodes
+// are left out of full rate move broadcasts and only get position summaries. The prey, and nodes whose position is
+// not known yet, are never far. Returns nil if interest management is off, or if this node is the prey, since every
+// wolf needs to know where the prey is.
+func (n *Node) FarNodes(pos shared.Coord) map[string]bool {
+	radius := n.Config.InterestRadius
+	if radius <= 0 || n.Role == nil || n.Role.IsPrey() {
+		return nil
+	}
+	gameState := n.Role.GameState()
+	if gameState == nil {
+		return nil
+	}
+
+	far := make(map[string]bool)
+	self := n.Role.Identifier()
+	gameState.PlayerLocs.RLock()
+	defer gameState.PlayerLocs.RUnlock()
+	for id, loc := range gameState.PlayerLocs.Data {
+		if id == self || shared.IsPreyId(id) {
+			continue
+		}
+		if distance(pos, loc) > radius {
+			far[id] = true
+		}
+	}
+	return far
+}
+
+// Manhattan distance between two cells
+func distance(a shared.Coord, b shared.Coord) int {
+	dx := a.X - b.X
+	if dx < 0 {
+		dx = -dx
+	}
+	dy := a.Y - b.Y
+	if dy < 0 {
+		dy = -dy
+	}
+	return dx + dy
+}
+
+// Routine that sends a summary of this node's position to every node outside its interest radius every
+// SummaryInterval, so they still see it move, if less smoothly. A node is only sent a summary if this node has moved
+// since the last one it got. Returns straight away if interest management is off.
+func (n *Node) SendPositionSummaries() {
+	if n.Config.InterestRadius <= 0 || n.Config.SummaryInterval == 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(n.Config.SummaryInterval) * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			n.sendPositionSummary()
+		case <-n.Done():
+			return
+		}
+	}
+}
+
+func (n *Node) sendPositionSummary() {
+	n.summaries.Lock()
+	defer n.summaries.Unlock()
+	if n.summaries.last == nil {
+		return
+	}
+	pos := *n.summaries.last
+
+	far := n.FarNodes(pos)
+	var message []byte
+	for id := range far {
+		if sent, ok := n.summaries.sent[id]; ok && sent == pos {
+			continue
+		}
+		if message == nil {
+			message = sendMessage(n.Log, NodeMessage{
+				MessageType: shared.SummaryMessage,
+				Identifier:  n.Role.Identifier(),
+				Move:        n.CreateMove(&pos),
+				Addr:        n.LocalAddr.String(),
+				Seq:         n.SequenceNumber,
+			}, "Sendin' position summary")
+		}
+		n.MessagesToSend <- &PendingMessage{Recipient: id, Message: message}
+		n.summaries.sent[id] = pos
+	}
+
+	// Nodes that have come back into range get every move again, so the next summary they need is a fresh one
+	for id := range n.summaries.sent {
+		if !far[id] {
+			delete(n.summaries.sent, id)
+		}
+	}
+}
+
+// Handles a position summary from a node that is too far away to send this node its every move. Summaries are applied
+// like moves, but are not ACKed since the sender does not wait on far nodes.
+// Returns InvalidMoveError if the summarised position is not valid, or TeleportError if it is further than the player
+// could have moved since its last summary
+func (n *Node) HandleReceivedSummary(identifier string, move *shared.Coord, seq uint64) (err error) {
+	err = n.CheckMoveIsValid(*move)
+	if err != nil {
+		return err
+	}
+	err = n.CheckNotTeleporting(identifier, *move, seq)
+	if err != nil {
+		return err
+	}
+	n.acceptMove(identifier, *move, seq)
+	n.applyMove(identifier, *move, seq)
+	n.Role.GameStateChanged()
+	return nil
+}
@@ -0,0 +1,374 @@
+package peer
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"../wolferrors"
+	"../shared"
+)
+
+// How often a node sends its ledger digest to the other nodes so they can fill in each other's missing captures
+const LEDGER_SYNC_INTERVAL = 5 * time.Second
+
+// The number of captures sent in one ledgerEntry message. Each carries its confirmations, so one is about all that
+// fits in a datagram.
+const LEDGER_ENTRIES_PER_MESSAGE = 1
+
+// An append-only record of every certified capture of a prey this node knows about. Captures are only ever added, and the
+// scores are worked out from the whole ledger, so two nodes holding the same captures agree on the scores whatever
+// order the captures reached them in.
+type Ledger struct {
+	sync.Mutex
+
+	// Captures in the order this node heard about them
+	entries   []shared.CaptureEvent
+
+	// The index of each capture in entries, by CaptureId
+	index     map[string]int
+
+	// Each prey's recent signed moves by sequence number, kept as proof of where the prey was for this node's
+	// captures, by prey identifier
+	preyMoves map[string]map[uint64]shared.SignedMove
+}
+
+// Returns the id a capture is known by in the ledger. A wolf can only capture each prey once at each of that prey's
+// sequence numbers, so the three together identify the capture.
+func CaptureId(event *shared.CaptureEvent) string {
+	return captureId(event.Capturer, event.Prey, event.PreySeq)
+}
+
+func captureId(capturer string, prey string, preySeq uint64) string {
+	return capturer + "@" + prey + "@" + strconv.FormatUint(preySeq, 10)
+}
+
+// Returns the key captures of prey at preySeq are grouped under, to find which of them wins
+func preyKey(prey string, preySeq uint64) string {
+	return prey + "@" + strconv.FormatUint(preySeq, 10)
+}
+
+// Returns a copy of every capture in the ledger
+func (l *Ledger) Entries() []shared.CaptureEvent {
+	l.Lock()
+	defer l.Unlock()
+	entries := make([]shared.CaptureEvent, len(l.entries))
+	copy(entries, l.entries)
+	return entries
+}
+
+// Returns true if the capture with the given id is in the ledger
+func (l *Ledger) Has(id string) bool {
+	l.Lock()
+	defer l.Unlock()
+	_, ok := l.index[id]
+	return ok
+}
+
+// Returns the ids of every capture in the ledger, sorted
+func (l *Ledger) Ids() []string {
+	l.Lock()
+	defer l.Unlock()
+	ids := make([]string, 0, len(l.index))
+	for id := range l.index {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Returns the captures in the ledger whose ids are not in ids
+func (l *Ledger) Missing(ids []string) []shared.CaptureEvent {
+	theirs := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		theirs[id] = true
+	}
+	l.Lock()
+	defer l.Unlock()
+	var missing []shared.CaptureEvent
+	for _, event := range l.entries {
+		if !theirs[CaptureId(&event)] {
+			missing = append(missing, event)
+		}
+	}
+	return missing
+}
+
+// Works out the scores from the ledger. Where more than one wolf captured a prey at the same prey sequence number,
+// only the CaptureTieBreak winner gets the points. Every wolf with a capture in the ledger is in the result, even if
+// it has lost them all.
+// Returns the scores by identifier
+func (l *Ledger) Scores() map[string]int {
+	l.Lock()
+	defer l.Unlock()
+	scores := make(map[string]int)
+	for _, event := range l.entries {
+		scores[event.Capturer] = 0
+	}
+	for _, event := range l.winners() {
+		scores[event.Capturer] += event.Worth
+	}
+	return scores
+}
+
+// Returns the capture of prey that holds at preySeq, if there is one
+func (l *Ledger) Holder(prey string, preySeq uint64) (shared.CaptureEvent, bool) {
+	l.Lock()
+	defer l.Unlock()
+	event, ok := l.winners()[preyKey(prey, preySeq)]
+	return event, ok
+}
+
+// Must be called with the lock held
+func (l *Ledger) winners() map[string]shared.CaptureEvent {
+	winners := make(map[string]shared.CaptureEvent)
+	for _, event := range l.entries {
+		key := preyKey(event.Prey, event.PreySeq)
+		holder, ok := winners[key]
+		if !ok || CaptureTieBreak(event.PreySeq, event.Capturer, holder.Capturer) == event.Capturer {
+			winners[key] = event
+		}
+	}
+	return winners
+}
+
+// Appends event to the ledger. A capture that is already in the ledger is not added again; a different capture with
+// the same id means the wolf tried to capture a prey twice at one prey sequence number.
+// Returns true if the event was added, or an InvalidPreyCaptureError for a second capture under the same id
+func (l *Ledger) add(event shared.CaptureEvent) (bool, error) {
+	l.Lock()
+	defer l.Unlock()
+	id := CaptureId(&event)
+	if i, ok := l.index[id]; ok {
+		if l.entries[i].R != event.R || l.entries[i].S != event.S || l.entries[i].PreyPos != event.PreyPos {
+			return false, wolferrors.InvalidPreyCaptureError(id)
+		}
+		return false, nil
+	}
+	if l.index == nil {
+		l.index = make(map[string]int)
+	}
+	l.index[id] = len(l.entries)
+	l.entries = append(l.entries, event)
+	return true, nil
+}
+
+// Remembers prey's signed move at seq so this node can prove where the prey was if it captures it there. Moves
+// more than MAXMOVESTOKEEP behind it are forgotten.
+func (l *Ledger) RememberPreyMove(prey string, seq uint64, move shared.SignedMove) {
+	l.Lock()
+	defer l.Unlock()
+	if l.preyMoves == nil {
+		l.preyMoves = make(map[string]map[uint64]shared.SignedMove)
+	}
+	moves, ok := l.preyMoves[prey]
+	if !ok {
+		moves = make(map[uint64]shared.SignedMove)
+		l.preyMoves[prey] = moves
+	}
+	moves[seq] = move
+	for s := range moves {
+		if s+MAXMOVESTOKEEP < seq {
+			delete(moves, s)
+		}
+	}
+}
+
+// Forgets prey's signed moves from before seq, once the running window no longer holds them either
+func (l *Ledger) ForgetPreyMovesBefore(prey string, seq uint64) {
+	l.Lock()
+	defer l.Unlock()
+	for s := range l.preyMoves[prey] {
+		if s < seq {
+			delete(l.preyMoves[prey], s)
+		}
+	}
+}
+
+func (l *Ledger) preyMove(prey string, seq uint64) (shared.SignedMove, bool) {
+	l.Lock()
+	defer l.Unlock()
+	move, ok := l.preyMoves[prey][seq]
+	return move, ok
+}
+
+// Returns the bytes the capturer signs for event: everything but the signature and the confirmations
+func (n *Node) captureEventBytes(event *shared.CaptureEvent) []byte {
+	arr := strconv.AppendQuote(nil, n.Config.GameId)
+	arr = strconv.AppendQuote(arr, event.Capturer)
+	arr = strconv.AppendQuote(arr, event.Prey)
+	arr = strconv.AppendUint(arr, event.PreySeq, 10)
+	arr = strconv.AppendInt(arr, int64(event.PreyPos.X), 10)
+	arr = strconv.AppendInt(arr, int64(event.PreyPos.Y), 10)
+	arr = strconv.AppendInt(arr, int64(event.Worth), 10)
+	arr = strconv.AppendInt(arr, int64(event.Peers), 10)
+	arr = append(arr, event.PreyMove.MoveByte...)
+	return arr
+}
+
+// Returns the public key identifier signs with, which is this node's own key if identifier is this node. Safe to call
+// from any goroutine.
+func (n *Node) keyOf(identifier string) *ecdsa.PublicKey {
+	if n.Role != nil && identifier == n.Role.Identifier() {
+		return n.PubKey
+	}
+	n.nodesLock.RLock()
+	defer n.nodesLock.RUnlock()
+	return n.NodeKeys[identifier]
+}
+
+// Returns true if signed was signed with identifier's key. Nothing signed by a node this node has no key for is
+// taken, since it can not be checked.
+func (n *Node) signedBy(identifier string, signed *shared.SignedMove) bool {
+	publicKey := n.keyOf(identifier)
+	return publicKey != nil && n.CheckAuthenticityOfMove(publicKey, signed)
+}
+
+// Checks a capture can be trusted without having seen it happen: the capturer must have signed it, the captured prey
+// must have signed a move to the captured position, and the capture must be worth a single catch. If this node still has the
+// prey's move at the captured prey sequence number, the position must match it too.
+// With DeterministicPrey, the prey's signed move can be left out; the position must then match the one this node
+// worked out for the prey itself.
+// Returns InvalidPreyCaptureError if any check fails
+func (n *Node) VerifyCaptureEvent(event *shared.CaptureEvent) error {
+	return n.verifyCaptureEvent(event, true)
+}
+
+// Checks a capture as VerifyCaptureEvent does. If witnessed is false, a capture that has no signed prey move and is
+// too old for this node to have worked out the prey's position is let through; its confirmations have to vouch for it.
+func (n *Node) verifyCaptureEvent(event *shared.CaptureEvent, witnessed bool) error {
+	if event == nil {
+		return wolferrors.InvalidPreyCaptureError("nil")
+	}
+	id := CaptureId(event)
+	if event.Worth != n.Config.InitState.CatchWorth || !shared.IsPreyId(event.Prey) {
+		return wolferrors.InvalidPreyCaptureError(id)
+	}
+	signature := shared.SignedMove{MoveByte: n.captureEventBytes(event), R: event.R, S: event.S}
+	if !n.signedBy(event.Capturer, &signature) {
+		return wolferrors.InvalidPreyCaptureError(id)
+	}
+	signed := len(event.PreyMove.MoveByte) > 0 || !n.DeterministicPrey()
+	if signed {
+		if !n.signedBy(event.Prey, &event.PreyMove) {
+			return wolferrors.InvalidPreyCaptureError(id)
+		}
+		var preyPos shared.Coord
+		if err := json.Unmarshal(event.PreyMove.MoveByte, &preyPos); err != nil || preyPos != event.PreyPos {
+			return wolferrors.InvalidPreyCaptureError(id)
+		}
+	}
+	pos, ok := n.RW.PositionAt(event.Prey, event.PreySeq)
+	if (ok && pos != event.PreyPos) || (!ok && !signed && witnessed) {
+		return wolferrors.InvalidPreyCaptureError(id)
+	}
+	return n.CheckMoveIsValid(event.PreyPos)
+}
+
+// Checks a capture certificate and adds it to the ledger, updating the scores.
+// Returns InvalidPreyCaptureError if the capture does not hold up, or UncertifiedCaptureError if it was not confirmed
+// by a quorum
+func (n *Node) CheckCapture(event *shared.CaptureEvent) error {
+	err := n.VerifyCertificate(event)
+	if err != nil {
+		return err
+	}
+	added, err := n.Ledger.add(*event)
+	if err != nil {
+		return err
+	}
+	if added {
+		if holder, _ := n.Ledger.Holder(event.Prey, event.PreySeq); holder.Capturer != event.Capturer {
+			fmt.Printf("%s loses the capture of %s at sequence %d to %s\n", event.Capturer, event.Prey, event.PreySeq,
+				holder.Capturer)
+		}
+		n.refreshScores()
+	}
+	return nil
+}
+
+// Overwrites the score of every player with a capture in the ledger with the score the ledger gives it. Players with
+// no captures in the ledger keep the score they have, such as one taken from another node's gamestate on joining.
+func (n *Node) refreshScores() {
+	gameState := n.Role.GameState()
+	if gameState == nil {
+		return
+	}
+	scores := n.Ledger.Scores()
+	gameState.PlayerScores.Lock()
+	for id, score := range scores {
+		gameState.PlayerScores.Data[id] = score
+	}
+	gameState.PlayerScores.Unlock()
+	n.Role.GameStateChanged()
+}
+
+// Routine that sends this node's ledger digest to every other node now and then, so captures that were missed
+// (dropped, or sent before this node joined) are filled in
+func (n *Node) RunLedgerSync() {
+	ticker := time.NewTicker(LEDGER_SYNC_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			n.SendLedgerDigest("all")
+		case <-n.Done():
+			return
+		}
+	}
+}
+
+// Sends the ids of every capture in this node's ledger to toSendID (or "all")
+func (n *Node) SendLedgerDigest(toSendID string) {
+	message := NodeMessage{
+		MessageType: shared.LedgerDigestMessage,
+		Identifier:  n.Role.Identifier(),
+		LedgerIds:   n.Ledger.Ids(),
+		Addr:        n.LocalAddr.String(),
+	}
+	n.Send(toSendID, message, "Sendin' ledger digest")
+}
+
+// Handles another node's ledger digest: any captures it is missing are sent to it, and if it has captures this node
+// is missing, this node's digest is sent back so it sends them over
+func (n *Node) HandleReceivedLedgerDigest(identifier string, ids []string) {
+	missing := n.Ledger.Missing(ids)
+	for len(missing) > 0 {
+		count := LEDGER_ENTRIES_PER_MESSAGE
+		if count > len(missing) {
+			count = len(missing)
+		}
+		message := NodeMessage{
+			MessageType: shared.LedgerEntryMessage,
+			Identifier:  n.Role.Identifier(),
+			Captures:    missing[:count],
+			Addr:        n.LocalAddr.String(),
+		}
+		n.Send(identifier, message, "Sendin' missing captures")
+		missing = missing[count:]
+	}
+
+	ours := make(map[string]bool)
+	for _, id := range n.Ledger.Ids() {
+		ours[id] = true
+	}
+	for _, id := range ids {
+		if !ours[id] {
+			n.SendLedgerDigest(identifier)
+			return
+		}
+	}
+}
+
+// Handles captures another node sent because this node's ledger was missing them
+func (n *Node) HandleReceivedLedgerEntries(identifier string, events []shared.CaptureEvent) {
+	for i := range events {
+		err := n.CheckCapture(&events[i])
+		if err != nil {
+			fmt.Println("Rejecting capture from", identifier, "ledger:", err)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package peer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	key "../key-helpers"
+	"../shared"
+)
+
+// The reveal timeout used when the config does not set one
+const DEFAULT_REVEAL_TIMEOUT = 300 * time.Millisecond
+
+// When each of the move commits in MoveCommits arrived. MoveCommits is only used by the listener, but these are also
+// read by the goroutine waiting to reveal this node's own move, so they need a lock.
+type commitTimes struct {
+	sync.Mutex
+	received map[string]time.Time
+}
+
+func (n *Node) revealTimeout() time.Duration {
+	if n.Config.RevealTimeout == 0 {
+		return DEFAULT_REVEAL_TIMEOUT
+	}
+	return time.Duration(n.Config.RevealTimeout) * time.Millisecond
+}
+
+// Returns true if a move by identifier to move has to be committed to before it is revealed. Prey are never in
+// lockstep; they do not race anyone.
+func (n *Node) InLockstep(identifier string, move shared.Coord) bool {
+	return !shared.IsPreyId(identifier) && n.inLockstepRadius(move, n.Config.LockstepRadius)
+}
+
+func (n *Node) inLockstepRadius(move shared.Coord, radius int) bool {
+	switch n.Config.Lockstep {
+	case shared.LockstepGlobal:
+		return true
+	case shared.LockstepNearPrey:
+		if n.Role == nil {
+			return false
+		}
+		gameState := n.Role.GameState()
+		if gameState == nil {
+			return false
+		}
+		gameState.PlayerLocs.RLock()
+		defer gameState.PlayerLocs.RUnlock()
+		for id, prey := range gameState.PlayerLocs.Data {
+			if shared.IsPreyId(id) && distance(move, prey) <= radius {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+// Returns true if this node should commit to move before sending it. Other nodes may have seen the prey a move later
+// than this node has, so moves one cell outside the lockstep radius are committed to as well; committing to a move
+// that did not need it does no harm.
+func (n *Node) ShouldCommit(move shared.Coord) bool {
+	return n.inLockstepRadius(move, n.Config.LockstepRadius+1)
+}
+
+// Commits this node to move by sending a signed hash of it to every other node, then waits until every other wolf in
+// lockstep has committed to a move of its own, or half of the reveal timeout has gone by. The move should be sent
+// as usual (revealed) with SendMoveToNodes once this returns; it goes out with the nonce it was committed to with.
+func (n *Node) CommitToMove(move *shared.Coord) {
+	nonce := NewNonce()
+	hash := n.CalculateHash(*move, n.Role.Identifier(), n.SequenceNumber+1, nonce)
+	r, s, err := n.SignMoveCommit(hash)
+	if err != nil {
+		fmt.Println("could not sign move commit", err)
+		return
+	}
+	_, pubKeyString := key.Encode(n.PrivKey, n.PubKey)
+	n.SendMoveCommitToNodes(&shared.MoveCommit{MoveHash: hash, PubKey: pubKeyString, R: r.String(), S: s.String()})
+	n.revealNonce = nonce
+
+	deadline := time.Now().Add(n.revealTimeout() / 2)
+	for time.Now().Before(deadline) && !n.IsClosed() {
+		if n.lockstepPeersCommitted() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Returns true if this node holds a live commit from every other wolf whose position is in lockstep
+func (n *Node) lockstepPeersCommitted() bool {
+	if n.Role == nil {
+		return true
+	}
+	gameState := n.Role.GameState()
+	if gameState == nil {
+		return true
+	}
+	self := n.Role.Identifier()
+	locs := make(map[string]shared.Coord)
+	gameState.PlayerLocs.RLock()
+	for id, loc := range gameState.PlayerLocs.Data {
+		locs[id] = loc
+	}
+	gameState.PlayerLocs.RUnlock()
+
+	for id, loc := range locs {
+		if id == self || !n.InLockstep(id, loc) {
+			continue
+		}
+		if !n.hasLiveCommit(id) {
+			return false
+		}
+	}
+	return true
+}
+
+// Records that a commit from identifier has just arrived
+func (n *Node) commitReceived(identifier string) {
+	n.commitTimes.Lock()
+	defer n.commitTimes.Unlock()
+	if n.commitTimes.received == nil {
+		n.commitTimes.received = make(map[string]time.Time)
+	}
+	n.commitTimes.received[identifier] = time.Now()
+}
+
+// Returns true if identifier has committed to a move within the reveal timeout and not revealed it yet
+func (n *Node) hasLiveCommit(identifier string) bool {
+	n.commitTimes.Lock()
+	defer n.commitTimes.Unlock()
+	at, ok := n.commitTimes.received[identifier]
+	return ok && time.Since(at) <= n.revealTimeout()
+}
+
+// Forgets identifier's commit once it has been revealed (or has expired)
+func (n *Node) forgetCommit(identifier string) {
+	delete(n.MoveCommits, identifier)
+	n.commitTimes.Lock()
+	delete(n.commitTimes.received, identifier)
+	n.commitTimes.Unlock()
+}
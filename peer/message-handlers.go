@@ -0,0 +1,202 @@
+package peer
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+	"encoding/json"
+	"../wolferrors"
+	"../shared"
+)
+
+// Handles a single message received from another node
+type MessageHandler func(message *NodeMessage)
+
+// Returns the handlers for the message kinds every role understands
+func (n *Node) commonHandlers() map[shared.MessageKind]MessageHandler {
+	return map[shared.MessageKind]MessageHandler{
+		shared.GameStateMessage:    n.handleGameStateMessage,
+		shared.GameStateReqMessage: n.handleGameStateReqMessage,
+		shared.MoveCommitMessage:   n.handleMoveCommitMessage,
+		shared.MoveMessage:         n.handleMoveMessage,
+		shared.ConnectMessage:      n.handleConnectMessage,
+		shared.ConnectedMessage:    n.handleConnectedMessage,
+		shared.LeaveMessage:        n.handleLeaveMessage,
+		shared.SummaryMessage:      n.handleSummaryMessage,
+		shared.TickHashMessage:     n.handleTickHashMessage,
+		shared.LedgerDigestMessage: n.handleLedgerDigestMessage,
+		shared.LedgerEntryMessage:  n.handleLedgerEntryMessage,
+		shared.StateDigestMessage:  n.handleStateDigestMessage,
+		shared.StateRepairMessage:  n.handleStateRepairMessage,
+		shared.MoveRejectedMessage: n.handleMoveRejectedMessage,
+		shared.AccusationMessage:   n.handleAccusationMessage,
+		shared.CapturedMessage:     n.handleCapturedMessage,
+		shared.ConfirmMessage:      n.handleConfirmMessage,
+	}
+}
+
+// Registers handler as the function called for every received message of the given kind, replacing any handler
+// that was registered for it before
+func (n *Node) RegisterHandler(kind shared.MessageKind, handler MessageHandler) {
+	if n.Handlers == nil {
+		n.Handlers = make(map[shared.MessageKind]MessageHandler)
+	}
+	n.Handlers[kind] = handler
+}
+
+// Passes a received message to the handler registered for its kind. Messages of a kind with no registered handler
+// are counted and logged, then dropped, as are messages from players evicted for cheating.
+func (n *Node) Dispatch(message *NodeMessage) {
+	if n.Evicted(message.Identifier) {
+		return
+	}
+	handler, ok := n.Handlers[message.MessageType]
+	if !ok {
+		count := atomic.AddUint64(&n.unknownMessages, 1)
+		log.Printf("Dropping message of unknown kind %q from %q (%d unknown so far)\n",
+			message.MessageType, message.Identifier, count)
+		return
+	}
+	handler(message)
+}
+
+// Returns the number of received messages that were dropped because no handler was registered for their kind
+func (n *Node) UnknownMessageCount() uint64 {
+	return atomic.LoadUint64(&n.unknownMessages)
+}
+
+// Unmarshals the coordinate carried in a message, checking it was signed by the sender if checkSignature is set.
+// A message that does not check out is dropped without being held against the sender: anyone can put its identifier
+// on a datagram, so only messages signed with its key are evidence of it cheating.
+// Returns false if the message should be dropped.
+func (n *Node) UnpackSignedCoord(message *NodeMessage, checkSignature bool) (shared.Coord, bool) {
+	var coords shared.Coord
+	if checkSignature && !n.signedBy(message.Identifier, &message.Move) {
+		fmt.Println("False coordinates from", message.Identifier)
+		return coords, false
+	}
+	err := json.Unmarshal(message.Move.MoveByte, &coords)
+	if err != nil {
+		fmt.Println("Could not unmarshal")
+		fmt.Println(err)
+		return coords, false
+	}
+	return coords, true
+}
+
+func (n *Node) handleGameStateMessage(message *NodeMessage) {
+	n.HandleReceivedGameState(message.Identifier, message.Snapshot)
+}
+
+func (n *Node) handleGameStateReqMessage(message *NodeMessage) {
+	n.HandleGameStateConnReq(message.Identifier, message.Since)
+}
+
+func (n *Node) handleMoveCommitMessage(message *NodeMessage) {
+	n.HandleReceivedMoveCommit(message.Identifier, message.MoveCommit)
+}
+
+// Moves that are in lockstep (see shared.LockstepMode), or that the sender committed to anyway, are checked
+// against the sender's commit. A rejected move is sent back to the sender with the reason, signed. With
+// DeterministicPrey, a prey's moves are the steps it worked out for itself, and are only checked.
+func (n *Node) handleMoveMessage(message *NodeMessage) {
+	coords, ok := n.UnpackSignedCoord(message, true)
+	if !ok {
+		return
+	}
+	prey := shared.IsPreyId(message.Identifier)
+	if prey && n.DeterministicPrey() {
+		err := n.HandleReceivedPreyStep(message.Identifier, coords, message.Tick, message.Move)
+		if err != nil {
+			fmt.Println("Rejecting step from", message.Identifier, err)
+		}
+		return
+	}
+	if prey {
+		n.Ledger.RememberPreyMove(message.Identifier, message.Seq, message.Move)
+	}
+	var err error
+	if n.Ticking() {
+		err = n.HandleReceivedTickedMove(message.Identifier, &coords, message.Seq, message.Tick, message.Nonce)
+	} else if _, committed := n.MoveCommits[message.Identifier]; committed || n.InLockstep(message.Identifier, coords) {
+		err = n.HandleReceivedMoveL(message.Identifier, &coords, message.Seq, message.Nonce)
+	} else {
+		err = n.HandleReceivedMoveNL(message.Identifier, &coords, message.Seq)
+	}
+	if err != nil {
+		fmt.Println("Rejecting move from", message.Identifier, err)
+		n.recordInvalidMove(message.Identifier, coords, message.Seq, err)
+		// The move a replay reuses the sequence number of was accepted, and it is that one the player waits on
+		if _, replayed := err.(wolferrors.ReplayedMoveError); !replayed {
+			n.SendMoveRejection(message.Identifier, coords, message.Seq, err)
+		}
+	}
+	if oldest, ok := n.RW.OldestSeq(message.Identifier); ok && prey {
+		n.Ledger.ForgetPreyMovesBefore(message.Identifier, oldest)
+	}
+}
+
+// Records a move that was rejected for not being valid at all as evidence of cheating. Other rejections (a blocked
+// cell, a late input, a move commit that was lost or never sent) can happen to an honest player.
+func (n *Node) recordInvalidMove(identifier string, move shared.Coord, seq uint64, err error) {
+	switch err.(type) {
+	case wolferrors.InvalidMoveError, wolferrors.OutOfBoundsError:
+		n.RecordCheat(identifier, InvalidMoveCheat, seq, fmt.Sprintf("move to %v", move))
+	}
+}
+
+func (n *Node) handleConnectMessage(message *NodeMessage) {
+	n.HandleIncomingConnectionRequest(message.Identifier, message.Addr, message.PubKey)
+}
+
+func (n *Node) handleConnectedMessage(message *NodeMessage) {
+	// Do nothing
+}
+
+func (n *Node) handleLeaveMessage(message *NodeMessage) {
+	n.HandleLeave(message.Identifier, &message.Move)
+}
+
+func (n *Node) handleSummaryMessage(message *NodeMessage) {
+	coords, ok := n.UnpackSignedCoord(message, true)
+	if ok {
+		err := n.HandleReceivedSummary(message.Identifier, &coords, message.Seq)
+		n.recordInvalidMove(message.Identifier, coords, message.Seq, err)
+	}
+}
+
+func (n *Node) handleTickHashMessage(message *NodeMessage) {
+	n.HandleReceivedStateHash(message.Identifier, message.Tick, message.StateHash, &message.Move)
+}
+
+func (n *Node) handleLedgerDigestMessage(message *NodeMessage) {
+	n.HandleReceivedLedgerDigest(message.Identifier, message.LedgerIds)
+}
+
+func (n *Node) handleLedgerEntryMessage(message *NodeMessage) {
+	n.HandleReceivedLedgerEntries(message.Identifier, message.Captures)
+}
+
+func (n *Node) handleCapturedMessage(message *NodeMessage) {
+	n.HandleCaptureRequest(message.Identifier, message.Capture)
+}
+
+func (n *Node) handleConfirmMessage(message *NodeMessage) {
+	n.HandleReceivedCaptureConfirmation(message.Identifier, message.Prey, message.PreySeq, message.Confirmation)
+}
+
+func (n *Node) handleStateDigestMessage(message *NodeMessage) {
+	n.HandleReceivedStateDigest(message.Identifier, message.Digest)
+}
+
+func (n *Node) handleStateRepairMessage(message *NodeMessage) {
+	n.HandleReceivedStateRepair(message.Identifier, message.Snapshot)
+}
+
+func (n *Node) handleMoveRejectedMessage(message *NodeMessage) {
+	n.HandleMoveRejection(message.Identifier, &message.Move)
+}
+
+func (n *Node) handleAccusationMessage(message *NodeMessage) {
+	n.HandleReceivedAccusations(message.Identifier, message.Accusations)
+}
@@ -0,0 +1,38 @@
+package peer
+
+import (
+	"fmt"
+	"time"
+	"../wolferrors"
+	"../shared"
+)
+
+// How much faster than MoveInterval a wolf's moves may arrive on average before they count as too fast, which allows
+// for moves bunching up on the way over the network
+const MOVE_RATE_TOLERANCE = 0.75
+
+// The number of moves (this one included) a wolf must have made before its move rate is checked
+const MOVE_RATE_MIN_MOVES = 4
+
+// Checks that identifier's move at seq does not bring its average time between moves, over the moves in the running
+// window, under MoveInterval (less MOVE_RATE_TOLERANCE). The times are this node's own, taken as each move arrived.
+// Prey are not limited, since they move again straight away each time they are captured.
+// Returns MoveTooFastError, and records it as evidence of cheating, if the move came too soon
+func (n *Node) CheckMoveRate(identifier string, seq uint64) error {
+	if n.Config.MoveInterval == 0 || shared.IsPreyId(identifier) {
+		return nil
+	}
+	count, oldest := n.RW.Span(identifier)
+	if count+1 < MOVE_RATE_MIN_MOVES {
+		return nil
+	}
+	interval := time.Duration(n.Config.MoveInterval) * time.Millisecond
+	// The moves in the window and this one leave count gaps between them
+	least := time.Duration(float64(count) * float64(interval) * MOVE_RATE_TOLERANCE)
+	took := time.Since(oldest)
+	if took >= least {
+		return nil
+	}
+	n.RecordCheat(identifier, SpeedCheat, seq, fmt.Sprintf("%d moves in %v", count+1, took))
+	return wolferrors.MoveTooFastError(identifier)
+}
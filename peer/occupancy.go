@@ -0,0 +1,49 @@
+package peer
+
+import (
+	"crypto/sha256"
+	"strconv"
+	"../wolferrors"
+	"../shared"
+)
+
+// Checks a move by identifier to move against the occupancy rule in the config. Prey, and any move under
+// OccupancyStack, are never stopped.
+// Returns true if the move bounces (the wolf stays where it is), or a CellOccupiedError if the move is blocked
+func (n *Node) CheckOccupancy(identifier string, move shared.Coord) (bounce bool, err error) {
+	if n.Config.Occupancy == shared.OccupancyStack || shared.IsPreyId(identifier) || n.Role == nil {
+		return false, nil
+	}
+	gameState := n.Role.GameState()
+	gridManager := n.Role.GetGridManager()
+	if gameState == nil || gridManager == nil {
+		return false, nil
+	}
+	gameState.PlayerLocs.RLock()
+	occupier, occupied := gridManager.OccupiedBy(move, identifier, gameState.PlayerLocs.Data)
+	gameState.PlayerLocs.RUnlock()
+	if !occupied {
+		return false, nil
+	}
+	if n.Config.Occupancy == shared.OccupancyBounce {
+		return true, nil
+	}
+	return false, wolferrors.CellOccupiedError(occupier)
+}
+
+// Decides which of two wolves that captured the prey at the same prey sequence number gets the capture. Every node
+// comes to the same answer whichever capture it hears about first, and the same wolf does not always win.
+// Returns the winner
+func CaptureTieBreak(preySeq uint64, a string, b string) string {
+	if captureRank(preySeq, a) <= captureRank(preySeq, b) {
+		return a
+	}
+	return b
+}
+
+func captureRank(preySeq uint64, identifier string) string {
+	arr := strconv.AppendUint(nil, preySeq, 10)
+	arr = strconv.AppendQuote(arr, identifier)
+	hash := sha256.Sum256(arr)
+	return string(hash[:])
+}
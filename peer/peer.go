@@ -0,0 +1,1173 @@
+package peer
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"log"
+	"os"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/rand"
+	"time"
+	"encoding/gob"
+	"encoding/hex"
+	"strconv"
+	"github.com/rzlim08/GoVector/govec"
+	"math/big"
+	key "../key-helpers"
+	"../wolferrors"
+	"../shared"
+	"../geometry"
+	"sync"
+	"encoding/json"
+	"context"
+)
+
+// The part of a node that plays the game (a wolf or the prey). The peer Node owns registration, membership, signing
+// and dispatch, and calls back into its role for anything that depends on the game being played.
+type Role interface {
+	// The identifier this node uses in the messages it sends
+	Identifier() string
+
+	// Whether this node registers with the server as the prey
+	IsPrey() bool
+
+	// The game state received moves, game states and leaves are applied to; nil until the role is set up
+	GameState() *shared.GameState
+
+	// The grid manager received moves are checked against; nil until the role is set up
+	GetGridManager() *geometry.GridManager
+
+	// Called whenever a received message or a membership change has changed the game state
+	GameStateChanged()
+
+	// The handlers for the message kinds only this role understands, registered on top of the peer's own
+	RoleHandlers() map[shared.MessageKind]MessageHandler
+}
+
+// Node communication with the other player/prey nodes as well as the server, shared by every role
+type Node struct {
+	// The role this node is playing
+	Role				Role
+
+	// The public key of this nodes
+	PubKey 				*ecdsa.PublicKey
+
+	// The private key of this node, used to encrypt messages
+	PrivKey 			*ecdsa.PrivateKey
+
+	// The gameconfig for the game, primarily used here to form connections to the given nodes
+	Config 				shared.GameConfig
+
+	// The address of the server for this game
+	ServerAddr			string
+
+	// The RPC connection to the server
+	ServerConn 			*rpc.Client
+
+	// The UDP connection over which this node listens for messages from other nodes
+	IncomingMessages 	*net.UDPConn
+
+	// The address of this node's listener
+	LocalAddr			net.Addr
+
+	// The current map of identifiers to connections of nodes in play
+	OtherNodes 			map[string]*net.UDPConn
+
+	// The current map of identifiers to public keys of nodes in play
+	NodeKeys		    map[string]*ecdsa.PublicKey
+
+	// The GoVector log
+	Log 				*govec.GoLog
+
+	// A channel that, when written to, will stop heartbeats. Primarily for testing
+	HeartAttack 		chan bool
+
+	// A map to store move commits in before receiving their associated moves
+	MoveCommits			map[string]string
+
+	// Channel that messages are written to so they can be handled by the goroutine that deals with sending messages
+	// and managing the other nodes
+	MessagesToSend		chan *PendingMessage
+
+	// Channel that the identifiers of nodes to delete are added to so they can be handled by the goroutine that deals
+	// with sending messages and managing the other nodes
+	NodesToDelete		chan string
+
+	// Channel that the identifiers and connections of nodes to add to other nodes are sent to so they can be handled
+	// by the goroutine that deals with sending messages and managing the other nodes
+	NodesToAdd			chan *OtherNode
+
+	// A channel to write nodes that appear to have been shut down to
+	NodesWriteConnRefused chan string
+
+	// Keeps track of the number of failed messages between nodes
+	Strikes               StrikeLockMap // Heartbeat protocol between nodes
+
+	// A boolean set to false before this node has reconciled the gamestate when joining
+	HasGameState		  bool
+
+	// Running Window
+	RW					  RunningWindow
+
+	// The sequence number of the last move this node sent
+	SequenceNumber		  uint64
+
+	// The handler RunListener passes each received message to, by message kind
+	Handlers			  map[shared.MessageKind]MessageHandler
+
+	// The number of received messages dropped because they were of a kind with no handler
+	unknownMessages		  uint64
+
+	// Drops received messages from senders that go over their budget
+	Limiter				  *RateLimiter
+
+	// Where this node last moved to, and what the nodes outside the interest radius were last told
+	summaries			  positionSummaries
+
+	// Messages waiting to be sent as one batch per node
+	outbox				  outbox
+
+	// When the move commits in MoveCommits arrived, so late reveals can be rejected
+	commitTimes			  commitTimes
+
+	// The nonce this node committed to its next move with; sent along with the move, then cleared
+	revealNonce			  []byte
+
+	// Evidence of cheating this node has seen, by player
+	cheats				  cheatLog
+
+	// The last move accepted from each player, to check the next one against
+	accepted			  acceptedMoves
+
+	// Accusations of cheating this node has seen, and the players evicted for it
+	evictions			  evictions
+
+	// Recent snapshots of this node's gamestate, to send deltas from
+	snapshots			  snapshotHistory
+
+	// Moves waiting for their tick, and the state hashes of simulated ticks
+	ticks				  tickState
+
+	// Every certified capture of the prey this node knows about; scores are derived from it
+	Ledger				  Ledger
+
+	// This node's captures that are still waiting on confirmations
+	pending				  pendingCaptures
+
+	// Cancelled by Close(); every long running goroutine of this node returns once it is done
+	ctx					  context.Context
+	cancel				  context.CancelFunc
+
+	// Makes sure Close() only tears the node down once
+	closeOnce			  *sync.Once
+
+	// Held by ManageOtherNodes while it changes OtherNodes or NodeKeys, and by any other goroutine reading them
+	nodesLock			  sync.RWMutex
+}
+
+type StrikeLockMap struct {
+	sync.RWMutex
+	StrikeCount map[string]int
+}
+
+// A message for another node with a recipient and a byte-encoded message. If the recipient is "all", the message is
+// sent to every node in OtherNodes other than those in Except.
+type PendingMessage struct {
+	Recipient string
+	Message []byte
+	Except map[string]bool
+}
+
+// An othernode struct, used for storing node ids/conns before they are added to the OtherNodes map
+type OtherNode struct {
+	Identifier string
+	Conn *net.UDPConn
+	PubKey *ecdsa.PublicKey
+}
+
+// A playerinfo struct, provides identification information about this node: the address and public key
+type PlayerInfo struct {
+	Address 			net.Addr
+	PubKey 				ecdsa.PublicKey
+	Prey				bool
+}
+
+// The message struct that is sent for all node communication
+type NodeMessage struct {
+	// the id of the sending node
+	Identifier  string
+
+	// identifies the type of message so we know which registered handler to pass it to
+	MessageType shared.MessageKind
+
+	// a gamestate snapshot or delta, included if MessageType is "gameState" or (holding only the players that
+	// differ) "stateRepair"
+	Snapshot    *shared.StateSnapshot
+
+	// the version of the receiver's gamestate the sender last synced with, if the message type is "gamestateReq"
+	Since       uint64
+
+	// a move, included if the message type is move; for leave, moveRejected and tickHash messages, what the sender
+	// signed to vouch for the message
+	Move        shared.SignedMove
+
+	// a move commit, included if the message type is moveCommit
+	MoveCommit  *shared.MoveCommit
+
+	// a score, included if the message is a preyCapture
+	Score int
+
+	// A string representing th epublic key if this is a connect message
+	PubKey 	string
+
+	// the address to connect to the sending node over
+	Addr        string
+
+	// Keep track of sequence number for response ACKs
+	Seq			uint64
+
+	// The prey a capture is of, and the prey's sequence number it was captured at
+	Prey		string
+	PreySeq		uint64
+
+	// the nonce the move was committed to with, included if this is a move that was committed to first
+	Nonce		[]byte
+
+	// the tick a move is to be applied at, or the tick a state hash is for, when the game is ticking
+	Tick		uint64
+
+	// a hash of the sender's game state after simulating Tick, included if the message type is tickHash
+	StateHash	[]byte
+
+	// the capture of the prey, included if the message type is captured, rejected or captureCert
+	Capture		*shared.CaptureEvent
+
+	// the sender's confirmation of the receiver's capture at PreySeq, included if the message type is captureConfirm
+	Confirmation *shared.CaptureConfirmation
+
+	// the ids of every capture in the sender's ledger, included if the message type is ledgerDigest
+	LedgerIds	[]string
+
+	// captures the receiver's ledger is missing, included if the message type is ledgerEntry
+	Captures	[]shared.CaptureEvent
+
+	// a hash of each player's state in the sender's gamestate, included if the message type is stateDigest
+	Digest		map[string]uint64
+
+	// signed votes to evict a cheating player, included if the message type is accusation
+	Accusations []shared.Accusation
+}
+
+const STRIKE_OUT = 3
+
+// Creates a node with initial empty maps/channels and the handlers every role understands. SetRole must be called
+// before the node starts handling messages.
+func CreateNode(pubKey *ecdsa.PublicKey, privKey *ecdsa.PrivateKey, serverAddr string) (*Node) {
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &Node{
+		PubKey:                pubKey,
+		PrivKey:               privKey,
+		ServerAddr:            serverAddr,
+		OtherNodes:            make(map[string]*net.UDPConn),
+		NodeKeys:              make(map[string]*ecdsa.PublicKey),
+		HeartAttack:           make(chan bool),
+		MoveCommits:           make(map[string]string),
+		MessagesToSend:        make(chan *PendingMessage, 30),
+		NodesToDelete:         make(chan string, 5),
+		NodesToAdd:            make(chan *OtherNode, 10),
+		NodesWriteConnRefused: make(chan string, 30),
+		Strikes:               StrikeLockMap{StrikeCount:make(map[string]int)},
+		HasGameState: 		   false,
+		RW:		   			   RunningWindow{Map:make(map[string][]MoveSeq)},
+		ctx:				   ctx,
+		cancel:				   cancel,
+		closeOnce:			   &sync.Once{},
+		Limiter:			   NewRateLimiter(DefaultRateLimits()),
+		summaries:			   positionSummaries{sent: make(map[string]shared.Coord)},
+	}
+	n.Handlers = n.commonHandlers()
+	return n
+}
+
+// Sets the role this node plays and registers the role's handlers
+func (n *Node) SetRole(role Role) {
+	n.Role = role
+	for kind, handler := range role.RoleHandlers() {
+		n.RegisterHandler(kind, handler)
+	}
+}
+
+// Runs listener for messages from other nodes, should be run in a goroutine
+// Unmarshalls received messages and dispatches them to the handler registered for their kind
+func (n *Node) RunListener(listener *net.UDPConn, nodeListenerAddr string) {
+	// Start the listener
+	listener.SetReadBuffer(1048576)
+
+	// Decoding copies everything out of the buffer, so one buffer does for every datagram
+	buf := make([]byte, 2048)
+	for {
+		length, addr, err := listener.ReadFromUDP(buf)
+		if err != nil {
+			// The listener is closed by Close(), at which point there is nothing left to read
+			if n.IsClosed() {
+				return
+			}
+			fmt.Println(err)
+			continue
+		}
+
+		// A datagram may be a batch of several messages, which are handled as if they had come in one by one
+		for _, payload := range unpackBatch(buf[:length]) {
+			message := receiveMessage(n.Log, payload)
+			if n.Limiter != nil && !n.Limiter.Allow(message.Identifier, addr.String(), message.MessageType) {
+				continue
+			}
+			n.Dispatch(&message)
+		}
+	}
+}
+
+// Routine that handles all reads and writes of the OtherNodes map; single thread preventing concurrent iteration and write
+// exception. This routine therefore handles all sending of messages as well as that requires iteration over OtherNodes.
+// Other goroutines only look up who is in the game, through IsOtherNode, OtherNodeCount and keyOf, which hold the
+// lock this routine takes to add or delete nodes.
+// If the config has a BatchWindow, messages for the same node are held for up to that long and sent as one datagram.
+func (n *Node) ManageOtherNodes() {
+	for {
+		select {
+		case toSend := <-n.MessagesToSend :
+			if toSend.Recipient != "all" {
+				// Send to the single node
+				n.writeTo(toSend.Recipient, toSend.Message)
+			} else {
+				// Send the message to all nodes
+				n.sendMessageToNodes(toSend.Message, toSend.Except)
+			}
+		case <-n.outbox.flush:
+			n.flushBatches()
+		case toAdd := <- n.NodesToAdd:
+			// A node connected to again gets a new connection; the one it replaces is not used any more
+			if conn, ok := n.OtherNodes[toAdd.Identifier]; ok && conn != nil && conn != toAdd.Conn {
+				conn.Close()
+			}
+			n.nodesLock.Lock()
+			n.OtherNodes[toAdd.Identifier] = toAdd.Conn
+			n.NodeKeys[toAdd.Identifier] = toAdd.PubKey
+			n.nodesLock.Unlock()
+		case toDelete := <-n.NodesToDelete:
+			fmt.Printf("To delete: %s\n", toDelete)
+			if conn, ok := n.OtherNodes[toDelete]; ok {
+				conn.Close()
+			}
+			n.nodesLock.Lock()
+			delete(n.OtherNodes, toDelete)
+			delete(n.NodeKeys, toDelete)
+			n.nodesLock.Unlock()
+			if gameState := n.Role.GameState(); gameState != nil {
+				gameState.PlayerLocs.Lock()
+				delete(gameState.PlayerLocs.Data, toDelete)
+				delete(gameState.PlayerLocs.Seq, toDelete)
+				fmt.Printf("PlayerLocs.Data %v\n", gameState.PlayerLocs.Data)
+				gameState.PlayerLocs.Unlock()
+				n.Role.GameStateChanged()
+			}
+		case <-n.Done():
+			n.flushBatches()
+			n.flushAndCloseNodes()
+			return
+		}
+	}
+}
+
+// Writes out whatever is still waiting in MessagesToSend (such as the leave message) and closes every connection
+// to the other nodes. Only called by ManageOtherNodes once the node has been closed.
+func (n *Node) flushAndCloseNodes() {
+	for {
+		select {
+		case toSend := <-n.MessagesToSend:
+			if toSend.Recipient != "all" {
+				if conn, ok := n.OtherNodes[toSend.Recipient]; ok {
+					conn.Write(toSend.Message)
+				}
+			} else {
+				for id, conn := range n.OtherNodes {
+					if !toSend.Except[id] {
+						conn.Write(toSend.Message)
+					}
+				}
+			}
+		default:
+			n.nodesLock.Lock()
+			for id, conn := range n.OtherNodes {
+				conn.Close()
+				delete(n.OtherNodes, id)
+			}
+			n.nodesLock.Unlock()
+			return
+		}
+	}
+}
+
+// Returns true if identifier is one of the other nodes in play. Safe to call from any goroutine.
+func (n *Node) IsOtherNode(identifier string) bool {
+	n.nodesLock.RLock()
+	defer n.nodesLock.RUnlock()
+	_, ok := n.OtherNodes[identifier]
+	return ok
+}
+
+// Returns the number of other nodes in play. Safe to call from any goroutine.
+func (n *Node) OtherNodeCount() int {
+	n.nodesLock.RLock()
+	defer n.nodesLock.RUnlock()
+	return len(n.OtherNodes)
+}
+
+func (n *Node) PruneNodes() {
+	for {
+		select {
+		case id := <-n.NodesWriteConnRefused:
+			if !shared.IsPreyId(id) {
+				n.Strikes.StrikeCount[id]++
+				if n.Strikes.StrikeCount[id] > STRIKE_OUT {
+					n.NodesToDelete <- id
+					fmt.Printf("Deleting this id: %s\n", id)
+					delete(n.Strikes.StrikeCount, id)
+				}
+			}
+		case <-n.Done():
+			return
+		}
+	}
+}
+
+// Helper function that unpacks the GoVector message tooling
+// Returns the unmarshalled NodeMessage, ready for reading
+func receiveMessage(goLog *govec.GoLog, payload []byte) NodeMessage {
+	// Just removes the golog headers from each message
+	if goLog == nil{
+		return NodeMessage{Identifier: "Error"}
+	}
+	var message NodeMessage
+	goLog.UnpackReceive("LogicNodeReceiveMessage", payload, &message)
+	return message
+}
+
+// Helper function that packs the GoVector message tooling
+// Returns the byte-encoded message, ready to send
+func sendMessage(goLog *govec.GoLog, message NodeMessage, tag string) []byte{
+	if goLog == nil{
+		return nil
+	}
+	var newMessage []byte
+	if tag == ""{
+		newMessage = goLog.PrepareSend("SendMessageToOtherNode", message)
+	}else{
+		newMessage = goLog.PrepareSend(tag, message)
+	}
+
+	return newMessage
+
+}
+
+// Packs a message with the GoVector tooling and queues it for sending to recipient (or "all")
+func (n *Node) Send(recipient string, message NodeMessage, tag string) {
+	toSend := sendMessage(n.Log, message, tag)
+	n.MessagesToSend <- &PendingMessage{Recipient: recipient, Message: toSend}
+}
+
+// Registers the node with the server, receiving the game config (and connections)
+// Returns the unique id of this node assigned by the server
+func (n *Node) ServerRegister() (id string) {
+	gob.Register(&net.UDPAddr{})
+	gob.Register(&elliptic.CurveParams{})
+	gob.Register(&PlayerInfo{})
+
+	if n.ServerConn == nil {
+		response, err := DialAndRegister(n)
+		if err != nil {
+			os.Exit(1)
+		}
+		n.Log = govec.InitGoVectorMultipleExecutions("LogicNodeId-"+response.Identifier,
+			"LogicNodeFile")
+
+		n.Config = response
+		n.RW.Configure(n.Config)
+	}
+	n.GetNodes()
+
+	return n.Role.Identifier()
+}
+
+// Another server registration function, used to deal with server disconnection.
+func DialAndRegister(n *Node) (shared.GameConfig, error) {
+	// Connect to server with RPC, port is always :8081
+	serverConn, err := rpc.Dial("tcp", n.ServerAddr)
+	if err != nil {
+		log.Println("Cannot dial server. Please ensure the server is running and try again.")
+		return shared.GameConfig{}, err
+	}
+	// Storing in object so that we can do other RPC calls outside of this function
+	n.ServerConn = serverConn
+	var response shared.GameConfig
+	// Register with server
+	playerInfo := PlayerInfo{n.LocalAddr, *n.PubKey, n.Role.IsPrey()}
+	err = serverConn.Call("GServer.Register", playerInfo, &response)
+	if err != nil {
+		return shared.GameConfig{}, err
+	}
+	return response, nil
+}
+
+// Requests the list of currently connected nodes from the server, and initiates a connection with them
+func (n *Node) GetNodes() {
+	err := n.connectToNodes()
+	if err != nil {
+		panic(err)
+		log.Fatal(err)
+	}
+}
+
+// Gets the other nodes from the server and initiates a connection to each of them. Nodes this node already knows of
+// are connected to again, which gets it the gamestate changes and captures it missed while it was cut off.
+// Returns the error from the server, if any
+func (n *Node) connectToNodes() error {
+	var response map[string]shared.NodeRegistrationInfo
+	err := n.ServerConn.Call("GServer.GetNodes", *n.PubKey, &response)
+	if err != nil {
+		return err
+	}
+
+	// If 0, it is only us, don't need to update gamestate
+	if len(response) < 1 {
+		fmt.Println("no other nodes")
+		// This node is the only node in gameplay, doesn't need to get gamestate from other nodes
+		n.HasGameState = true
+	}
+
+	for id, regInfo := range response {
+		nodeClient := n.GetClientFromAddrString(regInfo.Addr.String())
+		pubKey:= key.StringToPubKey(regInfo.PubKey)
+		node := OtherNode{Identifier: id, Conn: nodeClient, PubKey: &pubKey}
+		n.NodesToAdd <- &node
+		n.InitiateConnection(id)
+	}
+	return nil
+}
+
+// Takes in an address string and makes a UDP connection to the client specified by the string. Returns the connection.
+func (n *Node) GetClientFromAddrString(addr string) (*net.UDPConn) {
+	nodeUdp, _ := net.ResolveUDPAddr("udp", addr)
+	// Connect to other node
+	nodeClient, err := net.DialUDP("udp", nil, nodeUdp)
+	if err != nil {
+		panic(err)
+	}
+	return nodeClient
+}
+
+// Sends a heartbeat to the server at the interval specificed at server registration. If the server lost track of this
+// node, it registers again and reconnects to the other nodes
+func (n *Node) SendHeartbeat() {
+	var _ignored bool
+	for {
+		select {
+		case <-n.HeartAttack:
+			return
+		case <-n.Done():
+			return
+		default:
+			err := n.ServerConn.Call("GServer.Heartbeat", *n.PubKey, &_ignored)
+			if err != nil {
+				if n.IsClosed() {
+					return
+				}
+				fmt.Printf("DEBUG - Heartbeat err: [%s]\n", err)
+				n.Config = n.Reregister()
+				n.RW.Configure(n.Config)
+				// Catch up on whatever happened while this node was cut off
+				if !n.IsClosed() {
+					if err := n.connectToNodes(); err != nil {
+						fmt.Printf("DEBUG - Reconnect err: [%s]\n", err)
+					}
+				}
+			}
+			// The server's heartbeat interval is in milliseconds
+			boop := n.Config.GlobalServerHB
+			select {
+			case <-time.After(time.Duration(boop/2)*time.Millisecond):
+			case <-n.Done():
+				return
+			}
+		}
+	}
+}
+
+// Function that is started when the server dies; will continue to reregister until the server comes back up
+func (n *Node) Reregister() shared.GameConfig {
+	response, register_failed_err := DialAndRegister(n)
+	for register_failed_err != nil {
+		// Don't keep trying to come back if we are leaving
+		if n.IsClosed() {
+			return n.Config
+		}
+		response, register_failed_err = DialAndRegister(n)
+		time.Sleep(time.Second)
+	}
+	fmt.Println("Registered Server")
+	return response
+}
+
+// Signs a new coordinate for this node and sends it under the next sequence number to all other nodes, other than
+// those outside the interest radius (see FarNodes). Returns the sequence number the move was sent with and the nodes
+// it was not sent to.
+func (n *Node) SendMoveToNodes(move *shared.Coord) (seq uint64, far map[string]bool) {
+	n.SequenceNumber++
+	far = n.FarNodes(*move)
+	message := NodeMessage{
+		MessageType: shared.MoveMessage,
+		Identifier:  n.Role.Identifier(),
+		Move:        n.CreateMove(move),
+		Addr:        n.LocalAddr.String(),
+		Seq:         n.SequenceNumber,
+		Nonce:       n.revealNonce,
+	}
+	n.revealNonce = nil
+	if n.Ticking() {
+		message.Tick = n.CurrentTick()
+		n.queueInput(n.Role.Identifier(), *move, n.SequenceNumber, message.Tick)
+	}
+	toSend := sendMessage(n.Log, message, "Sendin' move")
+	n.MessagesToSend <- &PendingMessage{Recipient: "all", Message: toSend, Except: far}
+	n.summaries.moved(*move)
+
+	return n.SequenceNumber, far
+}
+
+func (n *Node) CreateMove(move *shared.Coord) shared.SignedMove {
+	moveBytes, _ := json.Marshal(move)
+	return n.SignBytes(moveBytes)
+}
+
+// Signs an arbitrary payload with this node's private key so that other nodes can check it came from us
+func (n *Node) SignBytes(payload []byte) shared.SignedMove {
+	r, s, err := key.Sign(n.PrivKey, payload)
+	if err != nil {
+		fmt.Println("could not sign move")
+		panic(err)
+	}
+	moveId := shared.SignedMove{
+		payload,
+		r,
+		s,
+	}
+	return moveId
+}
+
+// Takes in a node ID and sends a full snapshot of this node's gamestate to that node
+func (n *Node) SendGameStateToNode(otherNodeId string){
+	n.SendGameStateSince(otherNodeId, 0)
+}
+
+// Sends the changes to this node's gamestate since version since to another node, or a full snapshot if since is 0
+// or too old
+func (n *Node) SendGameStateSince(otherNodeId string, since uint64){
+	message := NodeMessage{
+		MessageType: shared.GameStateMessage,
+		Identifier: n.Role.Identifier(),
+		Snapshot: n.SnapshotSince(since),
+		Addr: n.LocalAddr.String(),
+	}
+	n.Send(otherNodeId, message, "Sendin' gamestate")
+}
+
+// Sends a move commit to all other nodes, for lockstep protocol
+func (n *Node) SendMoveCommitToNodes(moveCommit *shared.MoveCommit) {
+	message := NodeMessage {
+		MessageType: shared.MoveCommitMessage,
+		Identifier:  n.Role.Identifier(),
+		MoveCommit:  moveCommit,
+		Addr:        n.LocalAddr.String(),
+	}
+	n.Send("all", message, "Sendin' move commit")
+}
+
+// Helper function to send message to other nodes; do not call directly; instead write to the messagesTosend channel
+func (n *Node) sendMessageToNodes(toSend []byte, except map[string]bool) {
+	for id := range n.OtherNodes{
+		if except[id] {
+			continue
+		}
+		n.writeTo(id, toSend)
+	}
+}
+
+// Handles a gamestate snapshot (or delta) received from another node. A joining node asks every node it connects to
+// for its gamestate (see InitiateConnection) and merges each reply in as it arrives: each player's position and score
+// are taken from the reply only if they come from a move at least as new as the one ours came from. This node's own
+// position is never taken from another node. A delta is only merged if it is against the version we last synced
+// with; otherwise a full snapshot is asked for.
+func (n *Node) HandleReceivedGameState(identifier string, snapshot *shared.StateSnapshot) {
+	if snapshot == nil {
+		return
+	}
+	if snapshot.Base != 0 && snapshot.Base != n.SyncedVersion(identifier) {
+		n.setSyncedVersion(identifier, 0)
+		n.RequestGameState(identifier)
+		return
+	}
+	if n.mergeSnapshot(snapshot, true) {
+		n.setSyncedVersion(identifier, snapshot.Version)
+		n.HasGameState = true
+	}
+}
+
+// Merges a snapshot into this node's gamestate, taking each other player's position if it comes from a move at least
+// as new as ours, and their score along with it if takeScores is set (or if we have no score for them). A player the
+// snapshot removed is only removed here if our position for it is no newer than the one they removed.
+// Returns false if there was nothing to merge
+func (n *Node) mergeSnapshot(snapshot *shared.StateSnapshot, takeScores bool) bool {
+	ours := n.Role.GameState()
+	if ours == nil || snapshot == nil {
+		return false
+	}
+	self := n.Role.Identifier()
+	ours.PlayerLocs.Lock()
+	defer ours.PlayerLocs.Unlock()
+	ours.PlayerScores.Lock()
+	defer ours.PlayerScores.Unlock()
+
+	newer := make(map[string]bool)
+	for id, pos := range snapshot.Locs {
+		if id != self && updatePosition(&ours.PlayerLocs, id, pos, snapshot.Seqs[id]) {
+			newer[id] = true
+		}
+	}
+	for id, seq := range snapshot.Removed {
+		if current, ok := ours.PlayerLocs.Seq[id]; id != self && (!ok || current <= seq) {
+			delete(ours.PlayerLocs.Data, id)
+			delete(ours.PlayerLocs.Seq, id)
+		}
+	}
+
+	if ours.PlayerScores.Data == nil {
+		ours.PlayerScores.Data = make(map[string]int)
+	}
+	for id, score := range snapshot.Scores {
+		if _, ok := ours.PlayerScores.Data[id]; !ok || (takeScores && newer[id]) {
+			ours.PlayerScores.Data[id] = score
+		}
+	}
+	return true
+}
+
+// Moves identifier to pos in locs, unless locs already has a position for identifier from a move at seq or newer.
+// Returns true if the position was updated. Must be called with the lock on locs held.
+func updatePosition(locs *shared.PlayerLockMap, identifier string, pos shared.Coord, seq uint64) bool {
+	if current, ok := locs.Seq[identifier]; ok && seq <= current {
+		return false
+	}
+	setPosition(locs, identifier, pos, seq)
+	return true
+}
+
+// Moves identifier to pos in locs, as its move at seq, whatever position it had. Must be called with the lock on locs
+// held.
+func setPosition(locs *shared.PlayerLockMap, identifier string, pos shared.Coord, seq uint64) {
+	if locs.Data == nil {
+		locs.Data = make(map[string]shared.Coord)
+	}
+	if locs.Seq == nil {
+		locs.Seq = make(map[string]uint64)
+	}
+	locs.Data[identifier] = pos
+	locs.Seq[identifier] = seq
+}
+
+// Handle moves that require a move commit check (lockstep)
+// Returns a MoveCommitMismatchError if the move does not match a received commit, a LateRevealError if the commit
+// was received more than the reveal timeout ago, a MoveTooFastError if the player is moving too fast, a
+// TeleportError if it moved further than it could have, a ReplayedMoveError if it reuses a sequence number, a
+// CellOccupiedError if it is blocked, or a BouncedMoveError if it bounces
+func (n *Node) HandleReceivedMoveL(identifier string, move *shared.Coord, seq uint64, nonce []byte) (err error) {
+	// Need nil check for bad move
+	if move == nil {
+		return wolferrors.InvalidMoveError("nil")
+	}
+	err = n.checkReveal(identifier, *move, seq, nonce)
+	if err != nil {
+		return err
+	}
+	// check to see if it's a valid move
+	err = n.CheckMoveIsValid(*move)
+	if err != nil {
+		return err
+	}
+	err = n.CheckMoveRate(identifier, seq)
+	if err != nil {
+		return err
+	}
+	err = n.CheckNotTeleporting(identifier, *move, seq)
+	if err != nil {
+		return err
+	}
+	bounce, err := n.CheckOccupancy(identifier, *move)
+	if err != nil {
+		return err
+	}
+	if bounce {
+		return wolferrors.BouncedMoveError(identifier)
+	}
+	n.applyMove(identifier, *move, seq)
+	n.Role.GameStateChanged()
+	n.acceptMove(identifier, *move, seq)
+	n.SendACK(identifier, seq)
+	n.RW.Add(identifier, seq, move)
+	return nil
+}
+
+// Handle moves that does not require a move commit check. A move onto another wolf's cell is handled according to
+// the occupancy rule: if it bounces, the wolf stays where it was and the move is rejected rather than ACKed, so the
+// mover rolls back to where it was too.
+// Returns InvalidMoveError if the received move is not valid, MoveTooFastError if the player is moving too fast,
+// TeleportError if it moved further than it could have, ReplayedMoveError if it reuses a sequence number,
+// CellOccupiedError if it is blocked, or BouncedMoveError if it bounces
+func (n *Node) HandleReceivedMoveNL(identifier string, move *shared.Coord, seq uint64) (err error) {
+	// Need nil check for bad move
+	if move == nil {
+		return wolferrors.InvalidMoveError("nil")
+	}
+	err = n.CheckMoveIsValid(*move)
+	if err != nil {
+		return err
+	}
+	err = n.CheckMoveRate(identifier, seq)
+	if err != nil {
+		return err
+	}
+	err = n.CheckNotTeleporting(identifier, *move, seq)
+	if err != nil {
+		return err
+	}
+	bounce, err := n.CheckOccupancy(identifier, *move)
+	if err != nil {
+		return err
+	}
+	if bounce {
+		return wolferrors.BouncedMoveError(identifier)
+	}
+	n.applyMove(identifier, *move, seq)
+	n.Role.GameStateChanged()
+	n.acceptMove(identifier, *move, seq)
+
+	// Prey do not wait for ACKs, so don't send any to them
+	if !shared.IsPreyId(identifier) {
+		n.SendACK(identifier, seq)
+	}
+	n.RW.Add(identifier, seq, move)
+	return nil
+}
+
+// Checks a revealed move against the commit identifier sent for it, then forgets the commit. A commit can be lost on
+// the way, or not sent because the player did not think it was in lockstep, so a move that does not match one is not
+// evidence of cheating the way an invalid move is.
+// Returns MoveCommitMismatchError if there is no commit the move matches, or LateRevealError if it came too late
+func (n *Node) checkReveal(identifier string, move shared.Coord, seq uint64, nonce []byte) error {
+	defer n.forgetCommit(identifier)
+	// if the player has previously submitted a move commit that's the same as the move
+	if !n.CheckMoveCommitAgainstMove(identifier, move, seq, nonce) {
+		return wolferrors.MoveCommitMismatchError("[" + strconv.Itoa(move.X) + ", " + strconv.Itoa(move.Y) + "]")
+	}
+	// the move has to be revealed before the commit runs out, or the player has had time to look ahead
+	if !n.hasLiveCommit(identifier) {
+		return wolferrors.LateRevealError(identifier)
+	}
+	return nil
+}
+
+// Moves this node to move, its own move with sequence number seq, in the role's game state. When ticking, this
+// node's own moves are applied with everyone else's by RunTicks instead.
+func (n *Node) ApplyOwnMove(move shared.Coord, seq uint64) {
+	if n.Ticking() {
+		return
+	}
+	n.applyMove(n.Role.Identifier(), move, seq)
+}
+
+// Moves identifier to move, its move with sequence number seq, in the role's game state. Moves that arrive after a
+// newer move from the same player are ignored.
+func (n *Node) applyMove(identifier string, move shared.Coord, seq uint64) {
+	gameState := n.Role.GameState()
+	if gameState == nil {
+		return
+	}
+	gameState.PlayerLocs.Lock()
+	updatePosition(&gameState.PlayerLocs, identifier, move, seq)
+	gameState.PlayerLocs.Unlock()
+}
+
+// Handles received move commits from other nodes by storing them in anticipation of receiving a move. The commit must
+// carry the key this node has for identifier, or anyone could fill identifier's commit slot with a commit of their own
+// and have its real reveals fail.
+// Returns IncorrectPlayerError if the player that send the message is not the player they are claiming to be
+func (n *Node) HandleReceivedMoveCommit(identifier string, moveCommit *shared.MoveCommit) (err error) {
+	publicKey := n.keyOf(identifier)
+	if moveCommit == nil || publicKey == nil || moveCommit.PubKey != key.EncodePublicKey(publicKey) ||
+		!n.CheckAuthenticityOfMoveCommit(moveCommit) {
+		return wolferrors.IncorrectPlayerError(identifier)
+	}
+	// if identifier doesn't have a commit waiting to be revealed, add move commit to map; a player can not swap
+	// its commit for another one once it has seen other players' commits
+	if _, ok := n.MoveCommits[identifier]; !ok || !n.hasLiveCommit(identifier) {
+		n.MoveCommits[identifier] = hex.EncodeToString(moveCommit.MoveHash)
+		n.commitReceived(identifier)
+	}
+	return nil
+}
+
+// Handles a "leave" message by removing the node that sent it straight away, rather than waiting for it to strike out.
+// The message is only accepted if it is signed by the leaving node.
+func (n *Node) HandleLeave(identifier string, signed *shared.SignedMove) {
+	if string(signed.MoveByte) != identifier || !n.signedBy(identifier, signed) {
+		fmt.Println("Ignoring leave message that was not signed by", identifier)
+		return
+	}
+	n.NodesToDelete <- identifier
+}
+
+// Handles "connect" messages received by other nodes by adding the incoming node to this node's OtherNodes, unless
+// it was evicted for cheating
+func (n *Node) HandleIncomingConnectionRequest(identifier string, addr string, pubKeyString string) {
+	if n.Evicted(identifier) {
+		fmt.Println("Refusing connection from evicted player", identifier)
+		return
+	}
+	node := n.GetClientFromAddrString(addr)
+	pubKey := key.StringToPubKey(pubKeyString)
+	n.NodesToAdd <- &OtherNode{Identifier: identifier, Conn: node, PubKey: &pubKey}
+}
+
+// If we are requested to send a gamestate, send it, or just the changes since version since if that is set
+func (n *Node) HandleGameStateConnReq(id string, since uint64) {
+	if n.Role.GameState() != nil {
+		n.SendGameStateSince(id, since)
+	}
+}
+
+// Initiates a connection to another node by sending it a "connect" message, asks it for its gamestate if this
+// node does not have one yet (or just the changes, if we synced with it before an outage), and starts reconciling our
+// capture ledgers
+func (n *Node) InitiateConnection(id string) {
+	message := NodeMessage{
+		MessageType: shared.ConnectMessage,
+		Identifier:  n.Role.Identifier(),
+		Addr:        n.LocalAddr.String(),
+		PubKey: 	 key.PubKeyToString(*n.PubKey),
+	}
+	n.Send(id, message, "Initiating connection")
+
+	if !n.HasGameState || n.SyncedVersion(id) != 0 {
+		n.RequestGameState(id)
+	}
+	// Whatever captures either of us is missing get sent over
+	n.SendLedgerDigest(id)
+}
+
+// Requests a gamestate from another node, used on joining and reconnecting. If we synced with it before, only the
+// changes since then are asked for.
+func (n *Node) RequestGameState(id string) {
+	message := NodeMessage {
+		MessageType: shared.GameStateReqMessage,
+		Identifier:  n.Role.Identifier(),
+		Since:       n.SyncedVersion(id),
+		Addr:        n.LocalAddr.String(),
+	}
+	n.Send(id, message, "Requesting gamestate")
+}
+
+// Tells every other node that this node is leaving the game
+func (n *Node) SendLeaveToNodes() {
+	message := NodeMessage {
+		MessageType: shared.LeaveMessage,
+		Identifier:  n.Role.Identifier(),
+		Move:        n.SignBytes([]byte(n.Role.Identifier())),
+		Addr:        n.LocalAddr.String(),
+	}
+	n.Send("all", message, "Leavin'")
+}
+
+// Leaves the game: tells the other nodes and the server that this node is going away, then stops every goroutine
+// started for this node and closes its sockets. Safe to call more than once.
+func (n *Node) Close() {
+	if n.closeOnce == nil {
+		return
+	}
+	n.closeOnce.Do(func() {
+		if n.LocalAddr != nil {
+			n.SendLeaveToNodes()
+		}
+		if n.ServerConn != nil {
+			var _ignored bool
+			err := n.ServerConn.Call("GServer.Deregister", *n.PubKey, &_ignored)
+			if err != nil {
+				fmt.Printf("DEBUG - Deregister err: [%s]\n", err)
+			}
+		}
+		n.cancel()
+		if n.IncomingMessages != nil {
+			n.IncomingMessages.Close()
+		}
+		if n.ServerConn != nil {
+			n.ServerConn.Close()
+		}
+	})
+}
+
+// Returns a channel that is closed once Close() has been called. Nodes that were not made with CreateNode are
+// never closed, so a nil channel (which blocks forever) is returned for them.
+func (n *Node) Done() <-chan struct{} {
+	if n.ctx == nil {
+		return nil
+	}
+	return n.ctx.Done()
+}
+
+// Returns true once Close() has been called
+func (n *Node) IsClosed() bool {
+	return n.ctx != nil && n.ctx.Err() != nil
+}
+
+func (n *Node) SendACK(identifier string, seq uint64) {
+	message := NodeMessage {
+		MessageType: shared.AckMessage,
+		Identifier: n.Role.Identifier(),
+		Seq: seq,
+		Addr: n.LocalAddr.String(),
+	}
+	n.Send(identifier, message, "Sendin' Ack")
+}
+
+////////////////////////////////////////////// MOVE COMMIT HASH FUNCTIONS //////////////////////////////////////////////
+
+// The number of random bytes a move is committed to along with; enough that nobody can work out a committed move
+// by hashing each of the few moves a player could make
+const NONCE_BYTES = 32
+
+// Returns a fresh random nonce to commit to a move with
+func NewNonce() []byte {
+	nonce := make([]byte, NONCE_BYTES)
+	if _, err := rand.Read(nonce); err != nil {
+		fmt.Println("could not make nonce")
+		panic(err)
+	}
+	return nonce
+}
+
+// Calculate the hash of the coordinates which will be sent at the move commitment stage. The hash covers the game id,
+// the player's identifier and the move's sequence number, so a commit can not be replayed for another move, and a
+// secret nonce, so the move can not be guessed from the hash.
+func (n *Node) CalculateHash(m shared.Coord, id string, seq uint64, nonce []byte) ([]byte) {
+	hash := sha256.New()
+	arr := make([]byte, 0, 128)
+
+	// Strings are quoted, so no two sets of fields run together into the same bytes
+	arr = strconv.AppendQuote(arr, n.Config.GameId)
+	arr = strconv.AppendQuote(arr, id)
+	arr = strconv.AppendUint(arr, seq, 10)
+	arr = append(arr, ',')
+	arr = strconv.AppendInt(arr, int64(m.X), 10)
+	arr = append(arr, ',')
+	arr = strconv.AppendInt(arr, int64(m.Y), 10)
+	arr = append(arr, ',')
+	arr = append(arr, nonce...)
+
+	// Write the hash
+	hash.Write(arr)
+	return hash.Sum(nil)
+}
+
+// Sign the move commit with private key
+func (n *Node) SignMoveCommit(hash []byte) (r, s *big.Int, err error) {
+	return ecdsa.Sign(rand.Reader, n.PrivKey, hash)
+}
+
+// Checks to see if the hash is legit: a SHA-256 hash signed by the key in the commit
+func (n *Node) CheckAuthenticityOfMoveCommit(m *shared.MoveCommit) (bool) {
+	if len(m.MoveHash) != sha256.Size {
+		return false
+	}
+	publicKey := key.PublicKeyStringToKey(m.PubKey)
+	rBigInt := new(big.Int)
+	_, err := fmt.Sscan(m.R, rBigInt)
+
+	sBigInt := new(big.Int)
+	_, err = fmt.Sscan(m.S, sBigInt)
+	if err != nil {
+		fmt.Println("Trouble converting string to big int")
+	}
+	return ecdsa.Verify(publicKey, m.MoveHash, rBigInt, sBigInt)
+}
+
+func (n *Node) CheckAuthenticityOfMove(publicKey *ecdsa.PublicKey, m *shared.SignedMove)(bool){
+	if publicKey == nil{
+		// public key is nil for some tests, just pass if this is the case
+		return true
+	}
+	return key.Verify(publicKey, m.MoveByte, m.R, m.S)
+}
+
+////////////////////////////////////////////// MOVE CHECK FUNCTIONS ////////////////////////////////////////////////////
+
+// Checks to see if there is an existing commit against the submitted move, revealed with the nonce it was committed
+// to with
+func (n *Node) CheckMoveCommitAgainstMove(identifier string, move shared.Coord, seq uint64, nonce []byte) (bool) {
+	if len(nonce) != NONCE_BYTES {
+		return false
+	}
+	hash := hex.EncodeToString(n.CalculateHash(move, identifier, seq, nonce))
+	for i, mc := range n.MoveCommits {
+		if mc == hash && i == identifier {
+			return true
+		}
+	}
+	return false
+}
+
+// Check move to see if it's valid based on the gameplay grid
+func (n *Node) CheckMoveIsValid(move shared.Coord) (err error) {
+	if n.Role == nil {
+		return nil
+	}
+	gridManager := n.Role.GetGridManager()
+	if gridManager == nil {
+		return nil
+	}
+	if !gridManager.IsInBounds(move) {
+		return wolferrors.OutOfBoundsError("[" + strconv.Itoa(move.X) + ", " + strconv.Itoa(move.Y) + "]")
+	}
+	if !gridManager.IsValidMove(move) {
+		return wolferrors.InvalidMoveError("[" + strconv.Itoa(move.X) + ", " + strconv.Itoa(move.Y) + "]")
+	}
+	return nil
+}
+
+// Finds the prey at move. Where more than one prey is on the cell, the one with the lowest identifier is caught.
+// Returns the prey's identifier, or InvalidPreyCaptureError if no prey is there
+func (n *Node) CheckGotPrey(move shared.Coord) (string, error) {
+	gameState := n.Role.GameState()
+	gameState.PlayerLocs.RLock()
+	defer gameState.PlayerLocs.RUnlock()
+	caught := ""
+	for id, prey := range gameState.PlayerLocs.Data {
+		if shared.IsPreyId(id) && prey == move && (caught == "" || id < caught) {
+			caught = id
+		}
+	}
+	if caught == "" {
+		return "", wolferrors.InvalidPreyCaptureError("[" + strconv.Itoa(move.X) + ", " + strconv.Itoa(move.Y) + "]")
+	}
+	return caught, nil
+}
@@ -0,0 +1,237 @@
+package peer
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"../geometry"
+	"../wolferrors"
+	"../shared"
+)
+
+// The chance of a worked-out prey step being a random one rather than fleeing, so the prey still wanders now and then
+const PREY_WANDER_CHANCE = 0.25
+
+// A step a prey sent for a tick, kept until this node has worked out the step itself
+type preyStep struct {
+	pos  shared.Coord
+	move shared.SignedMove
+}
+
+// Returns true if every node works out the prey's moves (see shared.GameConfig.DeterministicPrey)
+func (n *Node) DeterministicPrey() bool {
+	return n.Config.DeterministicPrey && n.Ticking()
+}
+
+// Returns the randomness for prey's step at tick. The same seed, tick and prey always give the same numbers, so every
+// node makes the same choices with it.
+func preyRand(seed int64, tick uint64, prey string) *rand.Rand {
+	arr := strconv.AppendInt(nil, seed, 10)
+	arr = append(arr, ';')
+	arr = strconv.AppendUint(arr, tick, 10)
+	arr = strconv.AppendQuote(arr, prey)
+	hash := sha256.Sum256(arr)
+	return rand.New(rand.NewSource(int64(binary.BigEndian.Uint64(hash[:8]))))
+}
+
+// Works out where prey steps to at tick from pos, given where the wolves are. A prey a wolf is standing on has been
+// caught and respawns far away. Otherwise it steps to whichever of the cells it can reach the nearest wolf would take
+// longest to walk to, going around walls, except now and then it takes a random step instead. Any choice is made
+// with the randomness for seed, tick and prey, so this is a function of its arguments alone.
+// Returns the prey's new position
+func PreyStep(seed int64, tick uint64, prey string, pos shared.Coord, wolves []shared.Coord,
+	grid *geometry.GridManager) shared.Coord {
+	r := preyRand(seed, tick, prey)
+	for _, wolf := range wolves {
+		if wolf == pos {
+			return grid.GetNewPosFrom(pos, r)
+		}
+	}
+	options := grid.Neighbours(pos)
+	if len(wolves) == 0 || r.Float64() < PREY_WANDER_CHANCE {
+		return options[r.Intn(len(options))]
+	}
+
+	dist := grid.Distances(wolves)
+	var best []shared.Coord
+	bestDist := -1
+	for _, cell := range options {
+		d, ok := dist[cell]
+		if !ok {
+			// No wolf can get there at all
+			d = math.MaxInt32
+		}
+		if d > bestDist {
+			best, bestDist = []shared.Coord{cell}, d
+		} else if d == bestDist {
+			best = append(best, cell)
+		}
+	}
+	return best[r.Intn(len(best))]
+}
+
+// Moves every prey one step for tick, worked out from the wolves' positions once the tick's moves are applied.
+// Must be called with the ticks lock held.
+// Returns each prey's new position
+func (n *Node) stepPrey(tick uint64) map[string]shared.Coord {
+	gameState := n.Role.GameState()
+	gridManager := n.Role.GetGridManager()
+	if gameState == nil || gridManager == nil {
+		return nil
+	}
+	positions := make(map[string]shared.Coord)
+	var prey []string
+	var wolves []shared.Coord
+	gameState.PlayerLocs.RLock()
+	for id, loc := range gameState.PlayerLocs.Data {
+		if shared.IsPreyId(id) {
+			prey = append(prey, id)
+			positions[id] = loc
+		} else {
+			wolves = append(wolves, loc)
+		}
+	}
+	gameState.PlayerLocs.RUnlock()
+
+	sort.Strings(prey)
+	for _, id := range prey {
+		next := PreyStep(n.Config.PreySeed, tick, id, positions[id], wolves, gridManager)
+		positions[id] = next
+		n.applyMove(id, next, tick)
+		n.RW.Add(id, tick, &next)
+	}
+	return positions
+}
+
+// Tells every other node the step this prey worked out for itself at tick, signed, so it can settle a disagreement
+// over where it is (see checkPreyStep)
+func (n *Node) sendPreyStep(tick uint64, pos shared.Coord) {
+	if n.LocalAddr == nil {
+		return
+	}
+	message := NodeMessage{
+		MessageType: shared.MoveMessage,
+		Identifier:  n.Role.Identifier(),
+		Move:        n.CreateMove(&pos),
+		Seq:         tick,
+		Tick:        tick,
+		Addr:        n.LocalAddr.String(),
+	}
+	n.Send("all", message, "Sendin' prey step")
+}
+
+// Handles the step prey says it took at tick. It is checked against the step this node works out itself, straight
+// away if this node has simulated tick already and otherwise once it has.
+// Returns PreyStepError if the step is not the one this node worked out
+func (n *Node) HandleReceivedPreyStep(prey string, pos shared.Coord, tick uint64, move shared.SignedMove) error {
+	n.ticks.Lock()
+	if tick > n.ticks.simulated {
+		if n.ticks.preySteps == nil {
+			n.ticks.preySteps = make(map[uint64]map[string]preyStep)
+		}
+		if n.ticks.preySteps[tick] == nil {
+			n.ticks.preySteps[tick] = make(map[string]preyStep)
+		}
+		n.ticks.preySteps[tick][prey] = preyStep{pos: pos, move: move}
+		n.ticks.Unlock()
+		return nil
+	}
+	diverged := n.recentlyDiverged(tick)
+	n.ticks.Unlock()
+	return n.checkPreyStep(prey, tick, preyStep{pos: pos, move: move}, diverged)
+}
+
+// Checks the step prey sent for tick against the one this node worked out. A step that matches is kept as proof of
+// where the prey was, for captures. One that does not is evidence of the prey cheating, unless this node's own game
+// state recently disagreed with another node's: then this node can not tell which of them worked the step out wrong,
+// and the prey's signed step breaks the tie, as long as it is a step the prey could have taken (see preyCouldStep).
+// A prey this node has no position for yet is taken at its word.
+// Returns PreyStepError if the step is rejected
+func (n *Node) checkPreyStep(prey string, tick uint64, step preyStep, diverged bool) error {
+	if err := n.CheckMoveIsValid(step.pos); err != nil {
+		return err
+	}
+	computed, ok := n.RW.PositionAt(prey, tick)
+	if ok && computed == step.pos {
+		n.Ledger.RememberPreyMove(prey, tick, step.move)
+		return nil
+	}
+	if !ok && n.hasPosition(prey) {
+		// Too old to check
+		return nil
+	}
+	if ok && !diverged {
+		n.RecordCheat(prey, PreyStepCheat, tick, fmt.Sprintf("step to %v instead of %v", step.pos, computed))
+		return wolferrors.PreyStepError(strconv.FormatUint(tick, 10))
+	}
+	if ok {
+		from, known := n.RW.PositionAt(prey, tick-1)
+		if !known {
+			// Where the prey stepped from is too old to check against
+			return wolferrors.PreyStepError(strconv.FormatUint(tick, 10))
+		}
+		if !n.preyCouldStep(prey, tick, from, step.pos) {
+			n.RecordCheat(prey, PreyStepCheat, tick, fmt.Sprintf("step from %v to %v", from, step.pos))
+			return wolferrors.PreyStepError(strconv.FormatUint(tick, 10))
+		}
+	}
+	pos := step.pos
+	n.replacePreyStep(prey, pos, tick)
+	n.RW.Add(prey, tick, &pos)
+	n.Ledger.RememberPreyMove(prey, tick, step.move)
+	n.Role.GameStateChanged()
+	return nil
+}
+
+// Returns true if prey could have stepped from from to to at tick: to from itself or a cell next to it, or, if a wolf
+// is on from, to where it respawns. The wolves are taken from where they are now, which is where they were at tick
+// when the step is checked as tick is simulated.
+func (n *Node) preyCouldStep(prey string, tick uint64, from shared.Coord, to shared.Coord) bool {
+	gameState := n.Role.GameState()
+	gridManager := n.Role.GetGridManager()
+	if gameState == nil || gridManager == nil {
+		return true
+	}
+	for _, cell := range gridManager.Neighbours(from) {
+		if cell == to {
+			return true
+		}
+	}
+	var wolves []shared.Coord
+	gameState.PlayerLocs.RLock()
+	for id, loc := range gameState.PlayerLocs.Data {
+		if !shared.IsPreyId(id) {
+			wolves = append(wolves, loc)
+		}
+	}
+	gameState.PlayerLocs.RUnlock()
+	// Away from a wolf, the step worked out is one of the cells next to from, so only a respawn gets this far
+	return PreyStep(n.Config.PreySeed, tick, prey, from, wolves, gridManager) == to
+}
+
+// Returns true if this node's game state has a position for identifier
+func (n *Node) hasPosition(identifier string) bool {
+	gameState := n.Role.GameState()
+	if gameState == nil {
+		return false
+	}
+	gameState.PlayerLocs.RLock()
+	defer gameState.PlayerLocs.RUnlock()
+	_, ok := gameState.PlayerLocs.Data[identifier]
+	return ok
+}
+
+// Moves prey to pos as its step at tick, replacing the step this node worked out for that tick
+func (n *Node) replacePreyStep(prey string, pos shared.Coord, tick uint64) {
+	gameState := n.Role.GameState()
+	if gameState == nil {
+		return
+	}
+	gameState.PlayerLocs.Lock()
+	setPosition(&gameState.PlayerLocs, prey, pos, tick)
+	gameState.PlayerLocs.Unlock()
+}
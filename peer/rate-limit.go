@@ -0,0 +1,217 @@
+package peer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+	"../shared"
+)
+
+// The budgets received messages are charged against. Each class has its own budget so that, for example, a flood of
+// gamestate requests can not use up the budget for moves.
+type MessageClass int
+
+const (
+	OtherClass MessageClass = iota
+	MoveClass
+	StateRequestClass
+	CaptureClass
+)
+
+func (c MessageClass) String() string {
+	switch c {
+	case MoveClass:
+		return "moves"
+	case StateRequestClass:
+		return "state requests"
+	case CaptureClass:
+		return "captures"
+	default:
+		return "other"
+	}
+}
+
+// Returns the class a message of the given kind is charged to
+func ClassOf(kind shared.MessageKind) MessageClass {
+	switch kind {
+	case shared.MoveMessage, shared.MoveCommitMessage, shared.SummaryMessage:
+		return MoveClass
+	case shared.GameStateReqMessage, shared.LedgerDigestMessage, shared.StateDigestMessage:
+		return StateRequestClass
+	case shared.CapturedMessage, shared.ConfirmMessage, shared.CaptureCertMessage:
+		return CaptureClass
+	default:
+		return OtherClass
+	}
+}
+
+// A token bucket budget: Rate tokens are added every second, up to Burst tokens
+type Budget struct {
+	Rate  float64
+	Burst float64
+}
+
+// The limits applied to received messages. Senders are limited by the address the message came from, not the
+// identifier it claims: that has not been checked yet when the limiter sees the message, so charging it would let
+// anyone use up, or get muted, another node's budget by claiming its identifier.
+type RateLimits struct {
+	// The budget for each message class; classes without a budget are not limited
+	Budgets   map[MessageClass]Budget
+
+	// A sender that goes over budget this many times in a row is muted
+	MuteAfter int
+
+	// How long a muted sender has all of its messages dropped for
+	MuteFor   time.Duration
+}
+
+// Returns limits generous enough for normal play (a bot moves every 400ms, a player as fast as they can press keys)
+func DefaultRateLimits() RateLimits {
+	return RateLimits{
+		Budgets: map[MessageClass]Budget{
+			MoveClass:         {Rate: 20, Burst: 40},
+			StateRequestClass: {Rate: 0.5, Burst: 3},
+			CaptureClass:      {Rate: 2, Burst: 5},
+		},
+		MuteAfter: 20,
+		MuteFor:   5 * time.Second,
+	}
+}
+
+// The number of senders the limiter keeps track of before it starts forgetting idle ones
+const MAX_TRACKED_SENDERS = 1024
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Refills the bucket for the time passed since it was last used and takes a token from it if there is one
+func (b *tokenBucket) take(budget Budget, now time.Time) bool {
+	b.tokens += now.Sub(b.last).Seconds() * budget.Rate
+	if b.tokens > budget.Burst {
+		b.tokens = budget.Burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// What the limiter knows about a single address
+type sender struct {
+	buckets    map[MessageClass]*tokenBucket
+	overBudget int
+	mutedUntil time.Time
+	lastSeen   time.Time
+}
+
+// Rate limits received messages per source address
+type RateLimiter struct {
+	sync.Mutex
+	limits     RateLimits
+	senders    map[string]*sender
+	dropped    map[MessageClass]uint64
+	mutedDrops uint64
+}
+
+func NewRateLimiter(limits RateLimits) *RateLimiter {
+	return &RateLimiter{
+		limits:  limits,
+		senders: make(map[string]*sender),
+		dropped: make(map[MessageClass]uint64),
+	}
+}
+
+// Returns true if a message of the given kind, received from addr and claiming to be from identifier, should be
+// handled. Messages that are over addr's budget, or from a muted address, are counted and should be dropped.
+func (rl *RateLimiter) Allow(identifier string, addr string, kind shared.MessageKind) bool {
+	rl.Lock()
+	defer rl.Unlock()
+
+	now := time.Now()
+	class := ClassOf(kind)
+	s := rl.getSender(addr, now)
+	if now.Before(s.mutedUntil) {
+		rl.mutedDrops++
+		return false
+	}
+
+	budget, limited := rl.limits.Budgets[class]
+	if !limited {
+		return true
+	}
+	if s.buckets[class] == nil {
+		s.buckets[class] = &tokenBucket{tokens: budget.Burst, last: now}
+	}
+	if s.buckets[class].take(budget, now) {
+		s.overBudget = 0
+		return true
+	}
+	rl.dropped[class]++
+	s.overBudget++
+	if rl.limits.MuteAfter > 0 && s.overBudget >= rl.limits.MuteAfter {
+		fmt.Printf("Muting %s (claiming to be %s) for %v after %d messages over budget\n", addr, identifier,
+			rl.limits.MuteFor, s.overBudget)
+		s.mutedUntil = now.Add(rl.limits.MuteFor)
+		s.overBudget = 0
+	}
+	return false
+}
+
+// Returns the sender for the address key, making it if needed. Must be called with the lock held.
+func (rl *RateLimiter) getSender(key string, now time.Time) *sender {
+	s, ok := rl.senders[key]
+	if !ok {
+		if len(rl.senders) >= MAX_TRACKED_SENDERS {
+			rl.forgetIdleSenders(now)
+		}
+		s = &sender{buckets: make(map[MessageClass]*tokenBucket)}
+		rl.senders[key] = s
+	}
+	s.lastSeen = now
+	return s
+}
+
+// Forgets senders that are not muted and have not sent anything for a minute; their buckets would be full again
+func (rl *RateLimiter) forgetIdleSenders(now time.Time) {
+	for key, s := range rl.senders {
+		if now.After(s.mutedUntil) && now.Sub(s.lastSeen) > time.Minute {
+			delete(rl.senders, key)
+		}
+	}
+}
+
+// Returns the number of messages dropped for being over budget, by class
+func (rl *RateLimiter) Dropped() map[MessageClass]uint64 {
+	rl.Lock()
+	defer rl.Unlock()
+	dropped := make(map[MessageClass]uint64)
+	for class, count := range rl.dropped {
+		dropped[class] = count
+	}
+	return dropped
+}
+
+// Returns the number of messages dropped because their sender was muted
+func (rl *RateLimiter) MutedDrops() uint64 {
+	rl.Lock()
+	defer rl.Unlock()
+	return rl.mutedDrops
+}
+
+// Returns the addresses that are currently muted
+func (rl *RateLimiter) Muted() []string {
+	rl.Lock()
+	defer rl.Unlock()
+	now := time.Now()
+	var muted []string
+	for key, s := range rl.senders {
+		if now.Before(s.mutedUntil) {
+			muted = append(muted, key)
+		}
+	}
+	return muted
+}
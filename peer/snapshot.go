@@ -0,0 +1,131 @@
+package peer
+
+import (
+	"sync"
+	"../shared"
+)
+
+// The number of past snapshots of this node's gamestate kept to make deltas from. A node asking for the changes since
+// an older version gets a full snapshot instead.
+const SNAPSHOTS_TO_KEEP = 32
+
+// This node's recent gamestate snapshots, and the version of each other node's gamestate this node last synced with
+type snapshotHistory struct {
+	sync.Mutex
+
+	// Past snapshots, oldest first
+	past   []shared.StateSnapshot
+
+	// The version of each node's gamestate we last merged in, by identifier
+	synced map[string]uint64
+}
+
+// Returns a copy of this node's gamestate, with no version set
+func (n *Node) copyState() shared.StateSnapshot {
+	snapshot := shared.StateSnapshot{
+		Locs:   make(map[string]shared.Coord),
+		Seqs:   make(map[string]uint64),
+		Scores: make(map[string]int),
+	}
+	gameState := n.Role.GameState()
+	if gameState == nil {
+		return snapshot
+	}
+	gameState.PlayerLocs.RLock()
+	for id, pos := range gameState.PlayerLocs.Data {
+		snapshot.Locs[id] = pos
+		snapshot.Seqs[id] = gameState.PlayerLocs.Seq[id]
+	}
+	gameState.PlayerLocs.RUnlock()
+	gameState.PlayerScores.RLock()
+	for id, score := range gameState.PlayerScores.Data {
+		snapshot.Scores[id] = score
+	}
+	gameState.PlayerScores.RUnlock()
+	return snapshot
+}
+
+// Takes a snapshot of this node's gamestate. It gets a new version only if the gamestate changed since the last one.
+// Returns the snapshot
+func (n *Node) Snapshot() shared.StateSnapshot {
+	current := n.copyState()
+	n.snapshots.Lock()
+	defer n.snapshots.Unlock()
+	if len(n.snapshots.past) > 0 {
+		last := n.snapshots.past[len(n.snapshots.past)-1]
+		delta := diffSnapshots(&last, &current)
+		if len(delta.Locs) == 0 && len(delta.Scores) == 0 && len(delta.Removed) == 0 {
+			return last
+		}
+		current.Version = last.Version + 1
+	} else {
+		current.Version = 1
+	}
+	n.snapshots.past = append(n.snapshots.past, current)
+	if len(n.snapshots.past) > SNAPSHOTS_TO_KEEP {
+		n.snapshots.past = n.snapshots.past[1:]
+	}
+	return current
+}
+
+// Returns what changed in this node's gamestate since version since, or a full snapshot if since is 0 or no longer
+// kept
+func (n *Node) SnapshotSince(since uint64) *shared.StateSnapshot {
+	current := n.Snapshot()
+	if since == 0 {
+		return &current
+	}
+	n.snapshots.Lock()
+	defer n.snapshots.Unlock()
+	for i := range n.snapshots.past {
+		if n.snapshots.past[i].Version == since {
+			return diffSnapshots(&n.snapshots.past[i], &current)
+		}
+	}
+	return &current
+}
+
+// Returns a delta holding what changed from base to current
+func diffSnapshots(base *shared.StateSnapshot, current *shared.StateSnapshot) *shared.StateSnapshot {
+	delta := &shared.StateSnapshot{
+		Version: current.Version,
+		Base:    base.Version,
+		Locs:    make(map[string]shared.Coord),
+		Seqs:    make(map[string]uint64),
+		Scores:  make(map[string]int),
+		Removed: make(map[string]uint64),
+	}
+	for id, pos := range current.Locs {
+		if old, ok := base.Locs[id]; !ok || old != pos || base.Seqs[id] != current.Seqs[id] {
+			delta.Locs[id] = pos
+			delta.Seqs[id] = current.Seqs[id]
+		}
+	}
+	for id := range base.Locs {
+		if _, ok := current.Locs[id]; !ok {
+			delta.Removed[id] = base.Seqs[id]
+		}
+	}
+	for id, score := range current.Scores {
+		if old, ok := base.Scores[id]; !ok || old != score {
+			delta.Scores[id] = score
+		}
+	}
+	return delta
+}
+
+// Returns the version of identifier's gamestate this node last synced with, 0 if it never has
+func (n *Node) SyncedVersion(identifier string) uint64 {
+	n.snapshots.Lock()
+	defer n.snapshots.Unlock()
+	return n.snapshots.synced[identifier]
+}
+
+func (n *Node) setSyncedVersion(identifier string, version uint64) {
+	n.snapshots.Lock()
+	defer n.snapshots.Unlock()
+	if n.snapshots.synced == nil {
+		n.snapshots.synced = make(map[string]uint64)
+	}
+	n.snapshots.synced[identifier] = version
+}
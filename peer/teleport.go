@@ -0,0 +1,113 @@
+package peer
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+	"../wolferrors"
+	"../shared"
+)
+
+// The last move accepted from each player, which the next one is checked against
+type acceptedMoves struct {
+	sync.Mutex
+	byPlayer map[string]acceptedMove
+}
+
+type acceptedMove struct {
+	pos shared.Coord
+	seq uint64
+	// When this node accepted it, by its own clock
+	at  time.Time
+}
+
+// Checks that identifier could have got to move with its move at seq, from the last move this node accepted from it.
+// A wolf moves at most one cell per move, so over a gap in sequence numbers (moves this node missed, or was not sent)
+// it can have moved as many cells as the gap. If the game limits how fast wolves move, it can not have made more
+// moves than the time since the last one allows either, so a wolf can not skip sequence numbers to jump ahead. A move
+// reusing the sequence number of the last accepted one is never taken, or a wolf could go anywhere with it. Moves
+// older than the last accepted one, a player's first move, and prey (which respawn anywhere) are not checked.
+// Returns TeleportError, and records it as evidence of cheating, if the move is too far, or ReplayedMoveError if it
+// reuses the last accepted sequence number (only recorded as evidence if it goes somewhere else, since the same move
+// twice may just be a duplicated datagram)
+func (n *Node) CheckNotTeleporting(identifier string, move shared.Coord, seq uint64) error {
+	if shared.IsPreyId(identifier) {
+		return nil
+	}
+	n.accepted.Lock()
+	last, ok := n.accepted.byPlayer[identifier]
+	n.accepted.Unlock()
+	if !ok || seq < last.seq {
+		return nil
+	}
+	if seq == last.seq {
+		if move != last.pos {
+			n.RecordCheat(identifier, TeleportCheat, seq, fmt.Sprintf("%v to %v reusing a sequence number", last.pos, move))
+		}
+		return wolferrors.ReplayedMoveError(identifier)
+	}
+	steps := seq - last.seq
+	if n.Config.MoveInterval != 0 {
+		interval := time.Duration(n.Config.MoveInterval) * time.Millisecond
+		if inTime := uint64(time.Since(last.at) / interval) + 1; inTime < steps {
+			steps = inTime
+		}
+	}
+	if uint64(distance(last.pos, move)) <= steps {
+		return nil
+	}
+	n.RecordCheat(identifier, TeleportCheat, seq, fmt.Sprintf("%v to %v in %d moves", last.pos, move, steps))
+	return wolferrors.TeleportError(identifier)
+}
+
+// Records identifier's move at seq as the last one accepted from it, unless a newer one already was
+func (n *Node) acceptMove(identifier string, move shared.Coord, seq uint64) {
+	n.accepted.Lock()
+	defer n.accepted.Unlock()
+	if n.accepted.byPlayer == nil {
+		n.accepted.byPlayer = make(map[string]acceptedMove)
+	}
+	if last, ok := n.accepted.byPlayer[identifier]; ok && last.seq >= seq {
+		return
+	}
+	n.accepted.byPlayer[identifier] = acceptedMove{pos: move, seq: seq, at: time.Now()}
+}
+
+// Tells identifier that its move at seq was rejected, and why. The reason is signed, so the player can show it to
+// others.
+func (n *Node) SendMoveRejection(identifier string, move shared.Coord, seq uint64, reason error) {
+	rejection := shared.MoveRejection{
+		Rejecter:   n.Role.Identifier(),
+		Identifier: identifier,
+		Seq:        seq,
+		Move:       move,
+		Reason:     reason.Error(),
+	}
+	rejectionBytes, _ := json.Marshal(rejection)
+	message := NodeMessage{
+		MessageType: shared.MoveRejectedMessage,
+		Identifier:  n.Role.Identifier(),
+		Move:        n.SignBytes(rejectionBytes),
+		Seq:         seq,
+		Addr:        n.LocalAddr.String(),
+	}
+	n.Send(identifier, message, "Sendin' move rejection")
+}
+
+// Handles another node's rejection of one of this node's moves, checking it was signed by the node that sent it.
+// Rejections from nodes this node has no key for are ignored, or anyone could fail this node's moves.
+// Returns the rejection, or nil if it does not check out
+func (n *Node) HandleMoveRejection(identifier string, signed *shared.SignedMove) *shared.MoveRejection {
+	if !n.signedBy(identifier, signed) {
+		fmt.Println("Ignoring move rejection that was not signed by", identifier)
+		return nil
+	}
+	var rejection shared.MoveRejection
+	err := json.Unmarshal(signed.MoveByte, &rejection)
+	if err != nil || rejection.Rejecter != identifier || rejection.Identifier != n.Role.Identifier() {
+		return nil
+	}
+	fmt.Printf("%s rejected our move %d to %v: %s\n", identifier, rejection.Seq, rejection.Move, rejection.Reason)
+	return &rejection
+}
@@ -0,0 +1,365 @@
+package peer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"../wolferrors"
+	"../shared"
+)
+
+// The tick delay used when the config does not set one
+const DEFAULT_TICK_DELAY = 2
+
+// The number of simulated ticks whose state hashes are kept around to compare with other nodes'
+const TICK_HASHES_TO_KEEP = 50
+
+// A move waiting for the tick it is to be applied at
+type tickInput struct {
+	Identifier string
+	Move       shared.Coord
+	Seq        uint64
+}
+
+// Moves waiting for their tick to be simulated, and the state hashes of the ticks that have been
+type tickState struct {
+	sync.Mutex
+
+	// Moves by the tick they are to be applied at
+	inputs    map[uint64][]tickInput
+
+	// The last tick that was simulated; moves for it or any earlier tick are too late
+	simulated uint64
+
+	// This node's state hash after each recently simulated tick
+	ours      map[uint64][]byte
+
+	// Other nodes' state hashes for ticks this node has not simulated yet, by tick and then by node
+	theirs    map[uint64]map[string][]byte
+
+	// The number of times another node's state hash did not match ours
+	diverged  uint64
+
+	// The latest tick another node's state hash did not match ours at
+	lastDiverged uint64
+
+	// Steps prey sent for ticks this node has not simulated yet, by tick and then by prey
+	preySteps map[uint64]map[string]preyStep
+}
+
+// Returns true if the game is ticking (see shared.GameConfig.TickInterval)
+func (n *Node) Ticking() bool {
+	return n.Config.TickInterval > 0
+}
+
+func (n *Node) tickInterval() time.Duration {
+	return time.Duration(n.Config.TickInterval) * time.Millisecond
+}
+
+func (n *Node) tickDelay() uint64 {
+	if n.Config.TickDelay == 0 {
+		return DEFAULT_TICK_DELAY
+	}
+	return uint64(n.Config.TickDelay)
+}
+
+// Returns the tick it is now. Every node works this out from the server's epoch, so they agree on it as closely as
+// their clocks agree.
+func (n *Node) CurrentTick() uint64 {
+	since := time.Since(time.Unix(0, n.Config.Epoch))
+	if !n.Ticking() || since < 0 {
+		return 0
+	}
+	return uint64(since / n.tickInterval())
+}
+
+// Queues move by identifier to be applied when tick is simulated.
+// Returns a LateInputError if tick has already been simulated.
+func (n *Node) queueInput(identifier string, move shared.Coord, seq uint64, tick uint64) error {
+	n.ticks.Lock()
+	defer n.ticks.Unlock()
+	if tick <= n.ticks.simulated {
+		return wolferrors.LateInputError(strconv.FormatUint(tick, 10))
+	}
+	if n.ticks.inputs == nil {
+		n.ticks.inputs = make(map[uint64][]tickInput)
+	}
+	n.ticks.inputs[tick] = append(n.ticks.inputs[tick], tickInput{Identifier: identifier, Move: move, Seq: seq})
+	return nil
+}
+
+// Handles a move received while the game is ticking: the move is checked, ACKed and queued for its tick, rather than
+// applied straight away. Moves in lockstep are checked against their commit first.
+// Returns InvalidMoveError if the move is not valid, MoveCommitMismatchError if it does not match its commit,
+// MoveTooFastError if the player is moving too fast, TeleportError if it moved further than it could have,
+// ReplayedMoveError if it reuses a sequence number, or LateInputError if it arrived after its tick was simulated
+func (n *Node) HandleReceivedTickedMove(identifier string, move *shared.Coord, seq uint64, tick uint64, nonce []byte) (err error) {
+	if move == nil {
+		return wolferrors.InvalidMoveError("nil")
+	}
+	if _, committed := n.MoveCommits[identifier]; committed || n.InLockstep(identifier, *move) {
+		err = n.checkReveal(identifier, *move, seq, nonce)
+		if err != nil {
+			return err
+		}
+	}
+	err = n.CheckMoveIsValid(*move)
+	if err != nil {
+		return err
+	}
+	err = n.CheckMoveRate(identifier, seq)
+	if err != nil {
+		return err
+	}
+	err = n.CheckNotTeleporting(identifier, *move, seq)
+	if err != nil {
+		return err
+	}
+	err = n.queueInput(identifier, *move, seq, tick)
+	if err != nil {
+		return err
+	}
+	n.acceptMove(identifier, *move, seq)
+
+	// Prey do not wait for ACKs, so don't send any to them
+	if !shared.IsPreyId(identifier) {
+		n.SendACK(identifier, seq)
+	}
+	n.RW.Add(identifier, seq, move)
+	return nil
+}
+
+// Routine that simulates each tick once the moves for it have had TickDelay ticks to arrive, then tells the other
+// nodes what its game state looks like afterwards. Returns straight away if the game is not ticking.
+func (n *Node) RunTicks() {
+	if !n.Ticking() {
+		return
+	}
+	ticker := time.NewTicker(n.tickInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			now := n.CurrentTick()
+			if now > n.tickDelay() {
+				n.SimulateTo(now - n.tickDelay())
+			}
+		case <-n.Done():
+			return
+		}
+	}
+}
+
+// Simulates every tick up to and including tick that has not been simulated yet. The moves for each tick are applied
+// in order of identifier, then sequence number, so every node ends up in the same state; that includes which of two
+// wolves moving onto the same cell in a tick is blocked or bounced by the occupancy rule. With DeterministicPrey, the
+// prey then each take the step worked out for them, and the steps the prey sent for the tick are checked against it.
+func (n *Node) SimulateTo(tick uint64) {
+	n.ticks.Lock()
+	first := n.ticks.simulated + 1
+	if n.ticks.simulated == 0 && tick > TICK_HASHES_TO_KEEP {
+		// Nothing has been simulated yet, so there is no point going back to the start of the game
+		first = tick
+		for t := range n.ticks.inputs {
+			if t < first {
+				delete(n.ticks.inputs, t)
+			}
+		}
+		for t := range n.ticks.preySteps {
+			if t < first {
+				delete(n.ticks.preySteps, t)
+			}
+		}
+	}
+	hashes := make(map[uint64][]byte)
+	ownSteps := make(map[uint64]shared.Coord)
+	sentSteps := make(map[uint64]map[string]preyStep)
+	changed := false
+	for t := first; t <= tick; t++ {
+		inputs := n.ticks.inputs[t]
+		delete(n.ticks.inputs, t)
+		sort.Slice(inputs, func(i, j int) bool {
+			if inputs[i].Identifier != inputs[j].Identifier {
+				return inputs[i].Identifier < inputs[j].Identifier
+			}
+			return inputs[i].Seq < inputs[j].Seq
+		})
+		for _, input := range inputs {
+			if bounce, err := n.CheckOccupancy(input.Identifier, input.Move); bounce || err != nil {
+				continue
+			}
+			n.applyMove(input.Identifier, input.Move, input.Seq)
+			changed = true
+		}
+		if n.DeterministicPrey() {
+			positions := n.stepPrey(t)
+			if pos, ok := positions[n.Role.Identifier()]; ok {
+				ownSteps[t] = pos
+			}
+			sentSteps[t] = n.ticks.preySteps[t]
+			delete(n.ticks.preySteps, t)
+			changed = changed || len(positions) > 0
+		}
+		n.ticks.simulated = t
+		hashes[t] = n.StateHash()
+	}
+	n.ticks.Unlock()
+
+	if changed {
+		n.Role.GameStateChanged()
+	}
+	for t := first; t <= tick; t++ {
+		if pos, ok := ownSteps[t]; ok {
+			n.sendPreyStep(t, pos)
+		}
+		for prey, step := range sentSteps[t] {
+			if err := n.checkPreyStep(prey, t, step, n.RecentlyDiverged(t)); err != nil {
+				fmt.Println("Rejecting step from", prey, err)
+			}
+		}
+		n.recordStateHash(t, hashes[t])
+	}
+}
+
+// Returns a hash of the positions in this node's game state. Scores are left out, since they change when captures
+// are handled rather than at a tick.
+func (n *Node) StateHash() []byte {
+	gameState := n.Role.GameState()
+	if gameState == nil {
+		return nil
+	}
+	gameState.PlayerLocs.RLock()
+	ids := make([]string, 0, len(gameState.PlayerLocs.Data))
+	for id := range gameState.PlayerLocs.Data {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	arr := make([]byte, 0, 32*len(ids))
+	for _, id := range ids {
+		pos := gameState.PlayerLocs.Data[id]
+		arr = strconv.AppendQuote(arr, id)
+		arr = strconv.AppendInt(arr, int64(pos.X), 10)
+		arr = append(arr, ',')
+		arr = strconv.AppendInt(arr, int64(pos.Y), 10)
+		arr = append(arr, ';')
+	}
+	gameState.PlayerLocs.RUnlock()
+
+	hash := sha256.Sum256(arr)
+	return hash[:]
+}
+
+// Keeps this node's state hash for tick, checks it against the hashes other nodes have already sent for it, and
+// sends it to every other node
+func (n *Node) recordStateHash(tick uint64, hash []byte) {
+	n.ticks.Lock()
+	if n.ticks.ours == nil {
+		n.ticks.ours = make(map[uint64][]byte)
+	}
+	n.ticks.ours[tick] = hash
+	for id, theirs := range n.ticks.theirs[tick] {
+		n.compareStateHash(id, tick, theirs)
+	}
+	delete(n.ticks.theirs, tick)
+	for t := range n.ticks.ours {
+		if t+TICK_HASHES_TO_KEEP < tick {
+			delete(n.ticks.ours, t)
+		}
+	}
+	for t := range n.ticks.theirs {
+		if t+TICK_HASHES_TO_KEEP < tick {
+			delete(n.ticks.theirs, t)
+		}
+	}
+	n.ticks.Unlock()
+
+	if n.LocalAddr == nil {
+		return
+	}
+	message := NodeMessage{
+		MessageType: shared.TickHashMessage,
+		Identifier:  n.Role.Identifier(),
+		Move:        n.SignStateHash(tick, hash),
+		Tick:        tick,
+		StateHash:   hash,
+		Addr:        n.LocalAddr.String(),
+	}
+	n.Send("all", message, "Sendin' state hash")
+}
+
+// Signs hash as this node's state hash after tick, for HandleReceivedStateHash to check
+func (n *Node) SignStateHash(tick uint64, hash []byte) shared.SignedMove {
+	return n.SignBytes(stateHashBytes(tick, hash))
+}
+
+// Returns the bytes a node signs to vouch for hash being its state hash after tick
+func stateHashBytes(tick uint64, hash []byte) []byte {
+	arr := strconv.AppendUint(nil, tick, 10)
+	arr = append(arr, ';')
+	return append(arr, hash...)
+}
+
+// Handles another node's state hash for tick, checking it against ours if this node has simulated tick already. The
+// hash must be signed by the node it is from. Hashes from prey are not taken at all: a disagreement makes this node
+// take a prey's word for its steps (see checkPreyStep), so a prey could otherwise talk its way around the board.
+func (n *Node) HandleReceivedStateHash(identifier string, tick uint64, hash []byte, signed *shared.SignedMove) {
+	if shared.IsPreyId(identifier) {
+		return
+	}
+	if signed == nil || !bytes.Equal(signed.MoveByte, stateHashBytes(tick, hash)) || !n.signedBy(identifier, signed) {
+		fmt.Println("Ignoring state hash that was not signed by", identifier)
+		return
+	}
+	n.ticks.Lock()
+	defer n.ticks.Unlock()
+	if _, ok := n.ticks.ours[tick]; ok {
+		n.compareStateHash(identifier, tick, hash)
+		return
+	}
+	if tick <= n.ticks.simulated {
+		// Too old to compare
+		return
+	}
+	if n.ticks.theirs == nil {
+		n.ticks.theirs = make(map[uint64]map[string][]byte)
+	}
+	if n.ticks.theirs[tick] == nil {
+		n.ticks.theirs[tick] = make(map[string][]byte)
+	}
+	n.ticks.theirs[tick][identifier] = hash
+}
+
+// Must be called with the lock held and our hash for tick recorded
+func (n *Node) compareStateHash(identifier string, tick uint64, theirs []byte) {
+	if !bytes.Equal(n.ticks.ours[tick], theirs) {
+		n.ticks.diverged++
+		if tick > n.ticks.lastDiverged {
+			n.ticks.lastDiverged = tick
+		}
+		fmt.Printf("Game state diverged from %s at tick %d\n", identifier, tick)
+	}
+}
+
+// Returns true if another node's game state did not match this node's at some tick in the TICK_HASHES_TO_KEEP
+// ticks up to tick
+func (n *Node) RecentlyDiverged(tick uint64) bool {
+	n.ticks.Lock()
+	defer n.ticks.Unlock()
+	return n.recentlyDiverged(tick)
+}
+
+// Must be called with the lock held
+func (n *Node) recentlyDiverged(tick uint64) bool {
+	return n.ticks.diverged > 0 && n.ticks.lastDiverged+TICK_HASHES_TO_KEEP >= tick
+}
+
+// Returns the number of times another node's game state did not match this node's after the same tick
+func (n *Node) Divergences() uint64 {
+	n.ticks.Lock()
+	defer n.ticks.Unlock()
+	return n.ticks.diverged
+}
@@ -0,0 +1,200 @@
+package peer
+
+import (
+	"../shared"
+	"sync"
+	"time"
+)
+
+type MoveSeq struct{
+	seq 	uint64
+	coords	*shared.Coord
+	// When this node got the move, by its own clock
+	received time.Time
+}
+
+// A move held in the running window, as returned by its queries
+type WindowMove struct{
+	Seq			uint64
+	Pos			shared.Coord
+	// When this node got the move, by its own clock
+	Received	time.Time
+}
+
+// The number of moves kept for each player when the game config does not say otherwise
+const NUMMOVESTOKEEP = 10
+
+// The most moves kept for any one player however long they are retained, so a player flooding moves can not grow
+// the window without bound
+const MAXMOVESTOKEEP = 256
+
+// The classes of id the window keeps history for under separate policies
+const (
+	PreyWindowClass = "prey"
+	WolfWindowClass = "wolf"
+)
+
+// Returns the window class id belongs to
+func WindowClass(id string) string {
+	if shared.IsPreyId(id) {
+		return PreyWindowClass
+	}
+	return WolfWindowClass
+}
+
+// How much of the move history of one class of id the window keeps
+type WindowPolicy struct{
+	// The number of most recent moves always kept
+	Moves	int
+	// Older moves are kept too until they are this old; 0 keeps just the most recent Moves
+	Retain	time.Duration
+}
+
+// The policy for each class when the game config does not set one. A prey moves again as soon as it is captured, so
+// prey moves are also kept for a while to check captures that are claimed late.
+var DefaultWindowPolicies = map[string]WindowPolicy{
+	PreyWindowClass: {Moves: NUMMOVESTOKEEP, Retain: 2 * time.Second},
+	WolfWindowClass: {Moves: NUMMOVESTOKEEP},
+}
+
+type RunningWindow struct{
+	sync.Mutex
+	// Each id's moves, newest first
+	Map map[string][]MoveSeq
+	// The sequence number of each prey's latest move, by identifier
+	PreySeqs map[string]uint64
+	// The policy for each window class; classes without one here use DefaultWindowPolicies
+	Policies map[string]WindowPolicy
+}
+
+// Sets the policy for each window class the game config sets one for
+func(rw *RunningWindow)Configure(config shared.GameConfig){
+	rw.SetPolicy(PreyWindowClass, config.PreyWindow)
+	rw.SetPolicy(WolfWindowClass, config.WolfWindow)
+}
+
+// Sets how much history the window keeps for class, or goes back to the default if window is the zero value
+func(rw *RunningWindow)SetPolicy(class string, window shared.WindowConfig){
+	rw.Lock()
+	defer rw.Unlock()
+	if rw.Policies == nil{
+		rw.Policies = make(map[string]WindowPolicy)
+	}
+	if window.Moves == 0 && window.Retain == 0{
+		delete(rw.Policies, class)
+		return
+	}
+	rw.Policies[class] = WindowPolicy{window.Moves, time.Duration(window.Retain) * time.Millisecond}
+}
+
+// Must be called with the lock held
+func(rw *RunningWindow)policy(id string)WindowPolicy{
+	class := WindowClass(id)
+	if policy, ok := rw.Policies[class]; ok{
+		return policy
+	}
+	return DefaultWindowPolicies[class]
+}
+
+func(rw *RunningWindow)Add(id string, seq uint64, coords *shared.Coord){
+	rw.Lock()
+	defer rw.Unlock()
+	if rw.Map == nil{
+		rw.Map = make(map[string][]MoveSeq)
+	}
+	if shared.IsPreyId(id){
+		if rw.PreySeqs == nil{
+			rw.PreySeqs = make(map[string]uint64)
+		}
+		rw.PreySeqs[id] = seq
+	}
+	now := time.Now()
+	movSeq := append([]MoveSeq{{seq, coords, now}}, rw.Map[id]...)
+
+	// Drop the oldest moves beyond the policy's count that are past its retention
+	policy := rw.policy(id)
+	keep := len(movSeq)
+	for keep > policy.Moves && (policy.Retain == 0 || now.Sub(movSeq[keep-1].received) > policy.Retain){
+		keep--
+	}
+	if keep > MAXMOVESTOKEEP{
+		keep = MAXMOVESTOKEEP
+	}
+	rw.Map[id] = movSeq[:keep]
+}
+
+// Returns the sequence number of prey's latest move added to the window, 0 if there has not been one
+func(rw *RunningWindow)PreySeq(prey string)uint64{
+	rw.Lock()
+	defer rw.Unlock()
+	return rw.PreySeqs[prey]
+}
+
+// Returns true if the window holds id's move at seq, and it was to coords
+func(rw *RunningWindow)Match(id string, seq uint64, coords * shared.Coord)bool{
+	pos, ok := rw.PositionAt(id, seq)
+	return ok && coords != nil && pos == *coords
+}
+
+// Returns true if the window still holds id's move at seq
+func(rw *RunningWindow)Has(id string, seq uint64)bool{
+	_, ok := rw.PositionAt(id, seq)
+	return ok
+}
+
+// Returns where id's move at seq took it, if the window still holds that move
+func(rw *RunningWindow)PositionAt(id string, seq uint64)(shared.Coord, bool){
+	rw.Lock()
+	defer rw.Unlock()
+	for _, move := range rw.Map[id]{
+		if move.coords != nil && move.seq == seq{
+			return *move.coords, true
+		}
+	}
+	return shared.Coord{}, false
+}
+
+// Returns id's moves in the window that this node got within the last d, newest first
+func(rw *RunningWindow)Within(id string, d time.Duration)[]WindowMove{
+	rw.Lock()
+	defer rw.Unlock()
+	var moves []WindowMove
+	for _, move := range rw.Map[id]{
+		if move.coords == nil || time.Since(move.received) > d{
+			break
+		}
+		moves = append(moves, WindowMove{move.seq, *move.coords, move.received})
+	}
+	return moves
+}
+
+// Returns the lowest sequence number among id's moves in the window, or false if it holds none
+func(rw *RunningWindow)OldestSeq(id string)(uint64, bool){
+	rw.Lock()
+	defer rw.Unlock()
+	moves := rw.Map[id]
+	if len(moves) == 0{
+		return 0, false
+	}
+	oldest := moves[0].seq
+	for _, move := range moves{
+		if move.seq < oldest{
+			oldest = move.seq
+		}
+	}
+	return oldest, true
+}
+
+// Returns the number of id's moves in the window, and when the oldest of them arrived
+func(rw *RunningWindow)Span(id string)(count int, oldest time.Time){
+	rw.Lock()
+	defer rw.Unlock()
+	for _, move := range rw.Map[id]{
+		if move.coords == nil{
+			break
+		}
+		count++
+		oldest = move.received
+	}
+	return count, oldest
+}
@@ -0,0 +1,103 @@
+package impl
+
+import (
+	"math"
+	"time"
+	"github.com/faiface/pixel"
+)
+
+// How far behind the newest received state other players are drawn by default, so that there are usually two
+// received cells to draw them moving between
+const DEFAULT_RENDER_DELAY = 150 * time.Millisecond
+
+// How long past its newest received cell an entity keeps being drawn moving the way it was going. After that it is
+// drawn on that cell until a new one arrives.
+const MAX_EXTRAPOLATION = 250 * time.Millisecond
+
+// The number of received positions kept for each entity
+const ENTITY_HISTORY = 8
+
+type sample struct {
+	pos pixel.Vec
+	// When the pixel node received it
+	at  time.Time
+}
+
+// Recent positions of the other wolves and the prey, as received from the logic node, which are drawn interpolated
+// between rather than snapped to as they arrive
+type EntityHistories struct {
+	// How far behind the newest received state entities are drawn
+	Delay time.Duration
+
+	// Each entity's received positions, oldest first
+	byId  map[string][]sample
+}
+
+// Creates empty entity histories drawn delay behind the newest state
+func NewEntityHistories(delay time.Duration) *EntityHistories {
+	return &EntityHistories{Delay: delay, byId: make(map[string][]sample)}
+}
+
+// Records id's position as received at at
+func (h *EntityHistories) Record(id string, pos pixel.Vec, at time.Time) {
+	samples := append(h.byId[id], sample{pos, at})
+	if len(samples) > ENTITY_HISTORY {
+		samples = samples[len(samples)-ENTITY_HISTORY:]
+	}
+	h.byId[id] = samples
+}
+
+// Forgets every entity not in ids, such as a wolf that left or the prey once it is captured
+func (h *EntityHistories) Keep(ids map[string]bool) {
+	for id := range h.byId {
+		if !ids[id] {
+			delete(h.byId, id)
+		}
+	}
+}
+
+// Returns where to draw id at renderTime: Delay behind it, interpolated between the two positions received either
+// side of that time, or extrapolated from the last two for up to MAX_EXTRAPOLATION past the newest one. Positions
+// more than a cell apart (the prey respawning) are jumped between rather than slid across.
+// Returns false if nothing was received for id
+func (h *EntityHistories) PositionAt(id string, renderTime time.Time) (pixel.Vec, bool) {
+	samples := h.byId[id]
+	if len(samples) == 0 {
+		return pixel.Vec{}, false
+	}
+	t := renderTime.Add(-h.Delay)
+	if !t.After(samples[0].at) {
+		return samples[0].pos, true
+	}
+	for i := 1; i < len(samples); i++ {
+		if t.Before(samples[i].at) {
+			return blend(samples[i-1], samples[i], t), true
+		}
+	}
+
+	last := samples[len(samples)-1]
+	ahead := t.Sub(last.at)
+	if len(samples) < 2 || ahead > MAX_EXTRAPOLATION {
+		return last.pos, true
+	}
+	prev := samples[len(samples)-2]
+	if !adjacent(prev.pos, last.pos) || !last.at.After(prev.at) {
+		return last.pos, true
+	}
+	velocity := last.pos.Sub(prev.pos).Scaled(1 / last.at.Sub(prev.at).Seconds())
+	return last.pos.Add(velocity.Scaled(ahead.Seconds())), true
+}
+
+// Returns the position a fraction of the way from a to b that t is between them
+func blend(a sample, b sample, t time.Time) pixel.Vec {
+	if !adjacent(a.pos, b.pos) {
+		return a.pos
+	}
+	frac := float64(t.Sub(a.at)) / float64(b.at.Sub(a.at))
+	return pixel.Lerp(a.pos, b.pos, frac)
+}
+
+// Returns true if a and b are no more than one cell apart
+func adjacent(a pixel.Vec, b pixel.Vec) bool {
+	return math.Abs(a.X-b.X)+math.Abs(a.Y-b.Y) <= spriteStep
+}
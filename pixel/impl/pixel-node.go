@@ -16,6 +16,7 @@ import (
 	"sort"
 	"os"
 	"image/color"
+	"time"
 )
 
 var NodeAddr string // must store as global to get it into run function
@@ -54,6 +55,15 @@ type PixelNode struct {
 
 	// The text atlas which is required to draw text with pixel
 	TextAtlas  		  *text.Atlas
+
+	// Recent positions of the other players and the prey, which are drawn interpolated between
+	Remote			  *EntityHistories
+
+	// The newest game state received, which each frame is drawn from
+	latestState		  shared.GameRenderState
+
+	// Whether any game state has been received yet; until one is, the starting board is left as it was drawn
+	received		  bool
 }
 
 // Creates a pixel node by setting up the TCP connection with the logic node, and getting the associated game settings.
@@ -94,14 +104,43 @@ func CreatePixelNode(nodeAddr string) (PixelNode) {
 	basicAtlas := text.NewAtlas(basicfont.Face7x13, text.ASCII)
 
 	node := PixelNode{ Sender: remote, Geom: geom, NewGameStates: make(chan shared.GameRenderState, 5),
-	ScoreboardBg: scoreboardBg, TextAtlas: basicAtlas}
+	ScoreboardBg: scoreboardBg, TextAtlas: basicAtlas, Remote: NewEntityHistories(DEFAULT_RENDER_DELAY)}
 
 	return node
 }
 
-//
+// Takes in a newly received game state and draws it straight away
 func (pn * PixelNode) RenderNewState (win * pixelgl.Window, curState shared.GameRenderState) {
+	now := time.Now()
+	pn.ReceiveState(curState, now)
+	pn.RenderFrame(win, now)
+}
 
+// Takes in a game state received from the logic node at at, adding the other players' and the prey's positions to
+// their histories. Nothing is drawn until the next frame.
+func (pn * PixelNode) ReceiveState (curState shared.GameRenderState, at time.Time) {
+	present := make(map[string]bool)
+	for id, prey := range curState.Prey {
+		present[id] = true
+		pn.Remote.Record(id, pn.Geom.GetVectorFromCoords(prey), at)
+	}
+	for id, player := range curState.OtherPlayers {
+		present[id] = true
+		pn.Remote.Record(id, pn.Geom.GetVectorFromCoords(player), at)
+	}
+	pn.Remote.Keep(present)
+	pn.latestState = curState
+	pn.received = true
+}
+
+// Draws one frame at renderTime. The player is drawn where the newest state has it, which the logic node already
+// predicts; the other players and the prey are drawn a little in the past, moving smoothly between the cells they were
+// received on (see EntityHistories), so frames can be drawn at any rate whatever rate states arrive at.
+func (pn * PixelNode) RenderFrame (win * pixelgl.Window, renderTime time.Time) {
+	if !pn.received {
+		return
+	}
+	curState := pn.latestState
 
 	// Clear current render
 	win.Clear(color.RGBA{0x2d, 0x2d, 0x2d, 0xff})
@@ -112,14 +151,17 @@ func (pn * PixelNode) RenderNewState (win * pixelgl.Window, curState shared.Game
 	pn.DrawScore(win, curState)
 
 	// Render prey
-	preyPos := pn.Geom.GetVectorFromCoords(curState.Prey)
-	pMat := pixel.IM
-	pMat = pMat.Moved(preyPos)
-	pn.PreySprite.Draw(win, pMat)
+	for id := range curState.Prey {
+		if preyPos, ok := pn.Remote.PositionAt(id, renderTime); ok {
+			pn.PreySprite.Draw(win, pixel.IM.Moved(preyPos))
+		}
+	}
 
 	// Render other players
-	for _, player := range curState.OtherPlayers {
-		pn.OtherPlayerSprite.Draw(win, pixel.IM.Moved(pn.Geom.GetVectorFromCoords(player)))
+	for id := range curState.OtherPlayers {
+		if pos, ok := pn.Remote.PositionAt(id, renderTime); ok {
+			pn.OtherPlayerSprite.Draw(win, pixel.IM.Moved(pos))
+		}
 	}
 
 	// Render player
@@ -127,7 +169,6 @@ func (pn * PixelNode) RenderNewState (win * pixelgl.Window, curState shared.Game
 	mat := pixel.IM
 	mat = mat.Moved(playerPos)
 	pn.PlayerSprite.Draw(win, mat)
-
 }
 
 // Sends a move as inputted by the player to the logic node
@@ -9,24 +9,35 @@ import (
 	"../shared"
 	"image"
 	"image/color"
+	"strconv"
 	"time"
 )
 
 var nodeAddr string // must store as global to get it into run function
 
+// How far behind the newest state other players are drawn, in milliseconds; 0 uses impl.DEFAULT_RENDER_DELAY
+var renderDelay int
+
 // Main entrypoint, takes command line arguments to start the Pixel NOde
+// Usage: go run pixel.go [logic node address] [render delay in ms]
 func main() {
 	if len(os.Args) < 2 {
 		nodeAddr = ":12345" // use port 12345 on localhost for remote node if no input provided
 	} else {
 		nodeAddr = os.Args[1]
 	}
+	if len(os.Args) > 2 {
+		renderDelay, _ = strconv.Atoi(os.Args[2])
+	}
 	pixelgl.Run(run)
 }
 
 // This function is required to run pixel; it creates the pixel node and then runs pixel's game library in a loop
 func run() {
 	node := impl.CreatePixelNode(nodeAddr)
+	if renderDelay > 0 {
+		node.Remote.Delay = time.Duration(renderDelay) * time.Millisecond
+	}
 	go node.RunRemoteNodeListener()
 	winMaxX := node.Geom.GetX()
 	winMaxY := node.Geom.GetY()
@@ -112,12 +123,12 @@ func run() {
 			keyStroke = "down"
 		}
 
-		// Update game state
-		if len(node.NewGameStates) > 0 {
-			curState := <- node.NewGameStates
-			// Now, update the rendering
-			node.RenderNewState(win, curState)
+		// Take in every game state that has arrived, then draw a frame whether or not any did, so other players keep
+		// moving smoothly between states
+		for len(node.NewGameStates) > 0 {
+			node.ReceiveState(<-node.NewGameStates, time.Now())
 		}
+		node.RenderFrame(win, time.Now())
 		win.Update() // must be called frequently, or pixel will hang (can't update only when there is a new gamestate)
 	}
 }
@@ -3,9 +3,9 @@ package impl
 import (
 	"../../shared"
 	"../../geometry"
+	"../../peer"
 	"crypto/ecdsa"
 	"time"
-	"math/rand"
 	"fmt"
 )
 
@@ -33,6 +33,9 @@ type PreyNode struct {
 
 	// The game configuration provided upon registration from the server. Includes wall locations and board size.
 	GameConfig shared.InitialState
+
+	// Decides where the prey moves next
+	strategy   PreyStrategy
 }
 
 // nodeListenerAddr = where we expect to receive messages from other nodes
@@ -43,7 +46,7 @@ func CreatePreyNode(nodeListenerAddr, playerListenerAddr string,
 
 	// Start the node to node interface
 	nodeInterface := CreateNodeCommInterface(pubKey, privKey, serverAddr)
-	addr, listener := StartListenerUDP(nodeListenerAddr)
+	addr, listener := peer.StartListenerUDP(nodeListenerAddr)
 	nodeInterface.LocalAddr = addr
 	nodeInterface.IncomingMessages = listener
 	go nodeInterface.RunListener(listener, nodeListenerAddr)
@@ -52,10 +55,19 @@ func CreatePreyNode(nodeListenerAddr, playerListenerAddr string,
 	// Register with server, update info
 	uniqueId := nodeInterface.ServerRegister()
 	go nodeInterface.SendHeartbeat()
+	go nodeInterface.RunTicks()
+	go nodeInterface.RunLedgerSync()
+	go nodeInterface.RunAntiEntropy()
+	go nodeInterface.ReportDiagnostics()
 
-	// Make a gameState
+	geo := geometry.CreateNewGridManager(nodeInterface.Config.InitState.Settings)
+
+	// Make a gameState. The first prey starts where the wolves expect it; any more start anywhere.
 	playerLocs := make(map[string]shared.Coord)
 	playerLocs[uniqueId] = shared.Coord{5,5}
+	if uniqueId != shared.PreyIdentifier {
+		playerLocs[uniqueId] = geo.GetRandomValidPos()
+	}
 	playerMap := shared.PlayerLockMap{Data:playerLocs}
 
 	playerScores := make(map[string]int)
@@ -67,14 +79,21 @@ func CreatePreyNode(nodeListenerAddr, playerListenerAddr string,
 		PlayerScores: playerScoreMap,
 	}
 
+	strategy, err := SelectPreyStrategy(nodeInterface.Config.PreyStrategy)
+	if err != nil {
+		fmt.Println(err, "- using", DEFAULT_PREY_STRATEGY)
+		strategy, _ = SelectPreyStrategy(DEFAULT_PREY_STRATEGY)
+	}
+
 	// Create Prey node
 	pn := PreyNode{
-		nodeInterface:     &nodeInterface,
+		nodeInterface:     nodeInterface,
 		playerCommChannel: playerCommChannel,
-		geo:               geometry.CreateNewGridManager(nodeInterface.Config.InitState.Settings),
+		geo:               geo,
 		GameState:         gameState,
 		Identifier:        uniqueId,
 		GameConfig:        nodeInterface.Config.InitState,
+		strategy:          strategy,
 	}
 
 	// Allow the node-node interface to refer back to this node
@@ -84,93 +103,51 @@ func CreatePreyNode(nodeListenerAddr, playerListenerAddr string,
 }
 
 // Runs the main node (listens for incoming messages from pixel interface) in a loop, must be called at the
-// end of main (or alternatively, in a goroutine)
+// end of main (or alternatively, in a goroutine). With DeterministicPrey the strategy is not used: the prey's steps
+// are worked out as each tick is simulated, like every other node does, and sent from there.
 func (pn * PreyNode) RunGame(playerListener string) {
 	ticker := time.NewTicker(time.Millisecond * 250)
-	for _ = range ticker.C {
-		var dir string
-		randMove := rand.Float64()
-		if randMove < 0.25 || len(pn.GameState.PlayerLocs.Data) <2{
-			go func() {
-				random := rand.Float64()
-				switch {
-				case random < 0.25:
-					dir = "up"
-				case random < 0.5:
-					dir = "down"
-				case random < 0.75:
-					dir = "right"
-				default:
-					dir = "left"
-				}
-
-				move := pn.MovePrey(dir)
-				pn.nodeInterface.SendMoveToNodes(&move)
-			}()
-		}else {
-			go func() {
-				dir := "still"
-				maxVal := -1
-				for _,i:= range []int{-1,1}{
-					val := pn.MaxDistance(pn.GameState.PlayerLocs, i, 0)
-						if val > maxVal {
-							maxVal = val
-							if i == -1{
-								dir = "left"
-							}else{
-								dir = "right"
-							}
-
-					}
-
-				}
-				for _,j:= range []int{-1,1}{
-					val := pn.MaxDistance(pn.GameState.PlayerLocs, 0, j)
-					if val > maxVal{
-						maxVal = val
-						if j == -1{
-							dir = "down"
-						}else{
-							dir = "up"
-						}
-					}
-				}
-
-				fmt.Println("move prey: ", dir)
-				move := pn.MovePrey(dir)
-				pn.nodeInterface.SendMoveToNodes(&move)
-			}()
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-pn.nodeInterface.Done():
+			return
+		}
+		if pn.nodeInterface.DeterministicPrey() {
+			continue
 		}
+		dir := pn.strategy.NextMove(pn.View())
+		move := pn.MovePrey(dir)
+		pn.nodeInterface.SendMoveToNodes(&move)
 	}
 }
 
-func (pn *PreyNode)MaxDistance(playerLocs shared.PlayerLockMap, moveX, moveY int)int {
-	playerLocs.RLock()
-	myLoc := playerLocs.Data["prey"]
-	newLoc := shared.Coord{myLoc.X+moveX, myLoc.Y+moveY}
-	if !pn.geo.IsValidMove(newLoc)	{
-		return -1
-	}
-	dist := 0
-	for val, nodeLoc := range playerLocs.Data {
-		if val != "prey"{
-			dist += abs(newLoc.X-nodeLoc.X)+ abs(newLoc.Y-nodeLoc.Y)
+// Takes a snapshot of the board for the prey's strategy to decide its next move on. Other prey are left out; they
+// are no threat.
+// Returns the snapshot
+func (pn *PreyNode) View() PreyView {
+	view := PreyView{Grid: pn.geo}
+	pn.GameState.PlayerLocs.RLock()
+	defer pn.GameState.PlayerLocs.RUnlock()
+	for id, loc := range pn.GameState.PlayerLocs.Data {
+		if id == pn.Identifier {
+			view.Prey = loc
+		} else if !shared.IsPreyId(id) {
+			view.Wolves = append(view.Wolves, loc)
 		}
 	}
-	playerLocs.RUnlock()
-	return dist
+	return view
 }
-func abs(num int)int {
-	if num <0{
-		return -num
-	}else{
-		return num
-	}
+
+// Changes how the prey decides where to move
+func (pn *PreyNode) SetStrategy(strategy PreyStrategy) {
+	pn.strategy = strategy
 }
 
 func (pn * PreyNode) MovePrey(move string) (shared.Coord) {
 	pn.GameState.PlayerLocs.RLock()
-	preyLoc := pn.GameState.PlayerLocs.Data["prey"]
+	preyLoc := pn.GameState.PlayerLocs.Data[pn.Identifier]
 	pn.GameState.PlayerLocs.RUnlock()
 
 	originalPosition := shared.Coord{X: preyLoc.X, Y: preyLoc.Y}
@@ -188,15 +165,23 @@ func (pn * PreyNode) MovePrey(move string) (shared.Coord) {
 		newPosition.X = newPosition.X + 1
 	}
 	// Check new move is valid, if so update prey position
+	// When ticking, the new position is applied at the tick it is sent in, like everyone else's
 	if pn.geo.IsValidMove(newPosition) && pn.geo.IsNotTeleporting(originalPosition, newPosition){
-		pn.GameState.PlayerLocs.Lock()
-		pn.GameState.PlayerLocs.Data["prey"] = newPosition
-		pn.GameState.PlayerLocs.Unlock()
+		if !pn.nodeInterface.Ticking() {
+			pn.GameState.PlayerLocs.Lock()
+			pn.GameState.PlayerLocs.Data[pn.Identifier] = newPosition
+			pn.GameState.PlayerLocs.Unlock()
+		}
 		return newPosition
 	}
 	return preyLoc
 }
 
+// Leaves the game; RunGame returns once this has been called
+func (pn *PreyNode) Close() {
+	pn.nodeInterface.Close()
+}
+
 // GETTERS
 
 func (pn *PreyNode) GetNodeInterface() (*NodeCommInterface) {
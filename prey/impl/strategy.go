@@ -0,0 +1,298 @@
+package impl
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"../../geometry"
+	"../../shared"
+	"../../wolferrors"
+)
+
+// The strategy the prey uses when neither the game config nor the command line picks one
+const DEFAULT_PREY_STRATEGY = "greedy"
+
+// The score of a position where a wolf has caught the prey, before adding how many rounds were left to play
+const CAUGHT_SCORE = -1000000
+
+// The prey's strategies by the name they are picked by in GameConfig.PreyStrategy or on the command line
+var PreyStrategies = map[string]func() PreyStrategy{
+	"random":    func() PreyStrategy { return RandomStrategy{} },
+	"greedy":    func() PreyStrategy { return GreedyFleeStrategy{Wander: 0.25} },
+	"bfs":       func() PreyStrategy { return BFSFleeStrategy{} },
+	"lookahead": func() PreyStrategy { return LookaheadStrategy{Depth: 2, Wolves: 2} },
+}
+
+// Decides where the prey moves next
+type PreyStrategy interface {
+	// Returns the direction to move in: "up", "down", "left", "right" or "still"
+	NextMove(view PreyView) string
+}
+
+// A snapshot of the board taken for a strategy to decide the prey's next move on. It is the strategy's own copy, so
+// the game can carry on while the strategy thinks.
+type PreyView struct {
+	// The board, to check which cells can be moved to
+	Grid		geometry.GridManager
+
+	// Where the prey is
+	Prey		shared.Coord
+
+	// Where every wolf is
+	Wolves		[]shared.Coord
+}
+
+// Returns the strategy registered under name, or DEFAULT_PREY_STRATEGY's if name is empty.
+// Returns UnknownPreyStrategyError if there is no strategy by that name
+func SelectPreyStrategy(name string) (PreyStrategy, error) {
+	if name == "" {
+		name = DEFAULT_PREY_STRATEGY
+	}
+	create, ok := PreyStrategies[name]
+	if !ok {
+		return nil, wolferrors.UnknownPreyStrategyError(name)
+	}
+	return create(), nil
+}
+
+// The directions the prey can step in, in the order strategies try them
+var directions = []struct {
+	name   string
+	dx, dy int
+}{
+	{"left", -1, 0},
+	{"right", 1, 0},
+	{"down", 0, -1},
+	{"up", 0, 1},
+}
+
+// Returns the cell one step from pos in the direction dir ("still" stays put)
+func Step(pos shared.Coord, dir string) shared.Coord {
+	for _, d := range directions {
+		if d.name == dir {
+			return shared.Coord{X: pos.X + d.dx, Y: pos.Y + d.dy}
+		}
+	}
+	return pos
+}
+
+func randomDirection() string {
+	return directions[rand.Intn(len(directions))].name
+}
+
+func manhattan(a, b shared.Coord) int {
+	return abs(a.X-b.X) + abs(a.Y-b.Y)
+}
+
+func abs(num int) int {
+	if num < 0 {
+		return -num
+	}
+	return num
+}
+
+// Wanders at random, taking no notice of the wolves
+type RandomStrategy struct{}
+
+func (s RandomStrategy) NextMove(view PreyView) string {
+	return randomDirection()
+}
+
+// Steps to whichever neighbouring cell is furthest from the wolves in total, by Manhattan distance. Walls are only
+// noticed when they are right next to the prey, so it gets stuck in corners.
+type GreedyFleeStrategy struct {
+	// The chance, between 0 and 1, of taking a random step instead, so the prey is not entirely predictable
+	Wander	float64
+}
+
+func (s GreedyFleeStrategy) NextMove(view PreyView) string {
+	if len(view.Wolves) == 0 || rand.Float64() < s.Wander {
+		return randomDirection()
+	}
+	dir := "still"
+	maxVal := -1
+	for _, d := range directions {
+		next := shared.Coord{X: view.Prey.X + d.dx, Y: view.Prey.Y + d.dy}
+		if !view.Grid.IsValidMove(next) {
+			continue
+		}
+		val := 0
+		for _, wolf := range view.Wolves {
+			val += manhattan(next, wolf)
+		}
+		if val > maxVal {
+			maxVal = val
+			dir = d.name
+		}
+	}
+	return dir
+}
+
+// Steps to the neighbouring cell the nearest wolf would take longest to walk to, going around walls. Between equally
+// distant cells, the one with the most ways out is picked, so the prey keeps out of dead ends.
+type BFSFleeStrategy struct{}
+
+func (s BFSFleeStrategy) NextMove(view PreyView) string {
+	if len(view.Wolves) == 0 {
+		return randomDirection()
+	}
+	dist := view.Grid.Distances(view.Wolves)
+	distance := func(cell shared.Coord) int {
+		if d, ok := dist[cell]; ok {
+			return d
+		}
+		// No wolf can get there at all
+		return math.MaxInt32
+	}
+
+	dir := "still"
+	bestDist, bestExits := distance(view.Prey), len(view.Grid.Neighbours(view.Prey))
+	for _, d := range directions {
+		next := shared.Coord{X: view.Prey.X + d.dx, Y: view.Prey.Y + d.dy}
+		if !view.Grid.IsValidMove(next) {
+			continue
+		}
+		nextDist, nextExits := distance(next), len(view.Grid.Neighbours(next))
+		if nextDist > bestDist || (nextDist == bestDist && nextExits > bestExits) {
+			dir, bestDist, bestExits = d.name, nextDist, nextExits
+		}
+	}
+	return dir
+}
+
+// Plays the game out a few rounds ahead against the wolves nearest the prey, assuming they chase it as well as they
+// can, and picks the move that keeps the prey furthest from them (minimax). Every wolf's every move is tried, so the
+// cost grows quickly with Depth and Wolves.
+type LookaheadStrategy struct {
+	// The number of rounds, each a move of the prey followed by a move of every wolf, to look ahead
+	Depth	int
+
+	// The number of nearest wolves played against; the others are taken to stand still
+	Wolves	int
+}
+
+func (s LookaheadStrategy) NextMove(view PreyView) string {
+	if len(view.Wolves) == 0 {
+		return randomDirection()
+	}
+	wolves := make([]shared.Coord, len(view.Wolves))
+	copy(wolves, view.Wolves)
+	sort.Slice(wolves, func(i, j int) bool {
+		return manhattan(wolves[i], view.Prey) < manhattan(wolves[j], view.Prey)
+	})
+	chasing, standing := wolves, []shared.Coord(nil)
+	if s.Wolves > 0 && s.Wolves < len(wolves) {
+		chasing, standing = wolves[:s.Wolves], wolves[s.Wolves:]
+	}
+	search := lookahead{grid: &view.Grid, standing: standing}
+
+	dir := "still"
+	best := math.MinInt32
+	if search.caught(view.Prey, chasing) {
+		best = CAUGHT_SCORE
+	} else {
+		best = search.wolvesTurn(view.Prey, chasing, s.Depth, math.MinInt32, math.MaxInt32)
+	}
+	for _, d := range directions {
+		next := shared.Coord{X: view.Prey.X + d.dx, Y: view.Prey.Y + d.dy}
+		if !view.Grid.IsValidMove(next) {
+			continue
+		}
+		val := search.wolvesTurn(next, chasing, s.Depth, best, math.MaxInt32)
+		if val > best {
+			dir, best = d.name, val
+		}
+	}
+	return dir
+}
+
+// The state of one lookahead search
+type lookahead struct {
+	grid     *geometry.GridManager
+	standing []shared.Coord
+}
+
+// Returns true if a wolf is on the prey's cell
+func (l *lookahead) caught(prey shared.Coord, chasing []shared.Coord) bool {
+	for _, wolves := range [][]shared.Coord{chasing, l.standing} {
+		for _, wolf := range wolves {
+			if wolf == prey {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Scores a position where nobody has been caught: mostly by how close the nearest wolf is, then by how close they
+// all are
+func (l *lookahead) score(prey shared.Coord, chasing []shared.Coord) int {
+	nearest, total := math.MaxInt32, 0
+	for _, wolves := range [][]shared.Coord{chasing, l.standing} {
+		for _, wolf := range wolves {
+			d := manhattan(prey, wolf)
+			total += d
+			if d < nearest {
+				nearest = d
+			}
+		}
+	}
+	return nearest*1000 + total
+}
+
+// Returns the best score the prey can get from moving with depth rounds left, given the wolves play their best
+func (l *lookahead) preyTurn(prey shared.Coord, chasing []shared.Coord, depth, alpha, beta int) int {
+	if l.caught(prey, chasing) {
+		// Being caught sooner is worse
+		return CAUGHT_SCORE - depth
+	}
+	if depth == 0 {
+		return l.score(prey, chasing)
+	}
+	best := math.MinInt32
+	for _, next := range l.grid.Neighbours(prey) {
+		val := l.wolvesTurn(next, chasing, depth, alpha, beta)
+		if val > best {
+			best = val
+		}
+		if best > alpha {
+			alpha = best
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+	return best
+}
+
+// Returns the worst score the wolves can leave the prey with by moving, with depth rounds left counting this one
+func (l *lookahead) wolvesTurn(prey shared.Coord, chasing []shared.Coord, depth, alpha, beta int) int {
+	if l.caught(prey, chasing) {
+		return CAUGHT_SCORE - depth
+	}
+	best := math.MaxInt32
+	moved := make([]shared.Coord, len(chasing))
+	var try func(i int) bool
+	// Tries every move of chasing[i:], returning false once the rest can be skipped
+	try = func(i int) bool {
+		if i == len(chasing) {
+			val := l.preyTurn(prey, moved, depth-1, alpha, beta)
+			if val < best {
+				best = val
+			}
+			if best < beta {
+				beta = best
+			}
+			return alpha < beta
+		}
+		for _, next := range l.grid.Neighbours(chasing[i]) {
+			moved[i] = next
+			if !try(i + 1) {
+				return false
+			}
+		}
+		return true
+	}
+	try(0)
+	return best
+}
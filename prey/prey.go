@@ -16,7 +16,12 @@ func main() {
 	nodeListenerAddr := ":0"
 	playerListenerIpAddress := ":12345"
 	serverAddr := ":8081"
+	// The strategy from the game config is used unless another is named after the addresses
+	strategyName := ""
 	// Can start with an IP as param
+	if len(os.Args) > 4 {
+		strategyName = os.Args[4]
+	}
 	if len(os.Args) > 3 {
 		nodeListenerAddr = os.Args[1]
 		playerListenerIpAddress = os.Args[2]
@@ -31,5 +36,13 @@ func main() {
 
 	pubKey, privKey := key_helpers.GenerateKeys()
 	node := logicImpl.CreatePreyNode(nodeListenerAddr, playerListenerIpAddress, pubKey, privKey, serverAddr)
+	if strategyName != "" {
+		strategy, err := logicImpl.SelectPreyStrategy(strategyName)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		node.SetStrategy(strategy)
+	}
 	node.RunGame(playerListenerIpAddress)
 }
\ No newline at end of file
@@ -13,6 +13,7 @@ import (
 	"crypto/elliptic"
 	"strconv"
 	"os"
+	"math/rand"
 	keys "../key-helpers"
 )
 
@@ -36,8 +37,27 @@ type AllPlayers struct {
 var (
 	heartBeat = uint32(5000)
 	ping = uint32(3)
+	// Only used by the larger map; on the default map every player is always close enough to see every move
+	interestRadius = 6
+	summaryInterval = uint32(1000)
+	batchWindow = uint32(5)
+	lockstepRadius = 3
+	revealTimeout = uint32(300)
+	// Half the rate the pixel client sends keystrokes at (one every 200ms), so network jitter and the extra moves sent
+	// on a rollback do not make an honest wolf look too fast
+	moveInterval = uint32(100)
 	id = 0
+	// When this run of the server started, and a game id unique to it
+	epoch = time.Now().UnixNano()
+	gameId = strconv.FormatInt(epoch, 36)
+	// Only used by config "2", which runs the default map in ticks
+	tickInterval = uint32(100)
+	tickDelay = uint32(2)
+	// The seed the prey's moves are worked out from in config "2"
+	preySeed = rand.New(rand.NewSource(epoch)).Int63()
 	allPlayers = AllPlayers{all: make(map[string]*Player)}
+	// Keys of players evicted for cheating, which can not register again
+	bannedKeys = make(map[string]bool)
 )
 
 type PlayerInfo struct {
@@ -83,6 +103,11 @@ func main() {
 func monitor(pubKeyStr string, heartBeatInterval time.Duration) {
 	for {
 		allPlayers.Lock()
+		// The player deregistered itself
+		if _, ok := allPlayers.all[pubKeyStr]; !ok {
+			allPlayers.Unlock()
+			return
+		}
 		if time.Now().UnixNano() - allPlayers.all[pubKeyStr].RecentHB > int64(heartBeatInterval) {
 			fmt.Printf("Disconnected and deleted: %s\n", allPlayers.all[pubKeyStr].Address.String())
 			delete(allPlayers.all, pubKeyStr)
@@ -102,6 +127,11 @@ func (foo *GServer) Register(p PlayerInfo, response *shared.GameConfig) error {
 
 	pubKeyStr := keys.PubKeyToString(p.PubKey)
 
+	if bannedKeys[pubKeyStr] {
+		fmt.Printf("DEBUG - Banned Key Error [%s]\n", p.Address.String())
+		return wolferrors.BannedKeyError(p.Address.String())
+	}
+
 	// TODO: This needs to be fixed
 	//if player, exists := allPlayers.all[pubKeyStr]; exists {
 	//	fmt.Printf("DEBUG - Key Already Registered Error [%s]\n",
@@ -117,8 +147,18 @@ func (foo *GServer) Register(p PlayerInfo, response *shared.GameConfig) error {
 		}
 	}
 
+	// Each prey gets the lowest prey id no other player has
 	if p.Prey {
-		idStr = "prey"
+		taken := make(map[string]bool)
+		for _, player := range allPlayers.all {
+			taken[player.Identifier] = true
+		}
+		for n := 1; ; n++ {
+			if !taken[shared.PreyId(n)] {
+				idStr = shared.PreyId(n)
+				break
+			}
+		}
 	}
 
 	// once all checks are made to ensure that this connecting player has not already been registered,
@@ -182,6 +222,67 @@ func (foo *GServer) Heartbeat(key ecdsa.PublicKey, _ignored *bool) error {
 	return nil
 }
 
+// Removes a player that is leaving the game, so that it is no longer handed out by GetNodes
+func (foo *GServer) Deregister(key ecdsa.PublicKey, _ignored *bool) error {
+	allPlayers.Lock()
+	defer allPlayers.Unlock()
+
+	pubKeyStr := keys.PubKeyToString(key)
+
+	player, ok := allPlayers.all[pubKeyStr]
+	if !ok {
+		fmt.Println("DEBUG - Unknown Key Error")
+		return wolferrors.UnknownKeyError(pubKeyStr)
+	}
+
+	fmt.Printf("DEBUG - [%s] Left\n", player.Address.String())
+	delete(allPlayers.all, pubKeyStr)
+
+	return nil
+}
+
+// Bans the key of a player the nodes evicted for cheating, and removes the player from the game. The report must carry
+// accusations, signed by the players that made them, from a majority of the other registered players.
+func (foo *GServer) ReportCheater(report shared.EvictionReport, _ignored *bool) error {
+	allPlayers.Lock()
+	defer allPlayers.Unlock()
+
+	if bannedKeys[report.AccusedKey] {
+		return nil
+	}
+	accused, ok := allPlayers.all[report.AccusedKey]
+	if !ok || accused.Identifier != report.Accused {
+		fmt.Println("DEBUG - Unknown Key Error")
+		return wolferrors.UnknownKeyError(report.Accused)
+	}
+
+	witnesses := make(map[string]bool)
+	for _, accusation := range report.Accusations {
+		if accusation.Accused != report.Accused || accusation.AccusedKey != report.AccusedKey {
+			continue
+		}
+		for k, player := range allPlayers.all {
+			if player.Identifier != accusation.Witness || k == report.AccusedKey {
+				continue
+			}
+			witnessKey := keys.StringToPubKey(k)
+			if keys.Verify(&witnessKey, shared.AccusationBytes(accusation), accusation.R, accusation.S) {
+				witnesses[accusation.Witness] = true
+			}
+		}
+	}
+	if len(witnesses) < (len(allPlayers.all)-1)/2+1 {
+		fmt.Printf("DEBUG - Unproven accusation of [%s] by %d players\n", report.Accused, len(witnesses))
+		return wolferrors.UnprovenAccusationError(report.Accused)
+	}
+
+	fmt.Printf("DEBUG - [%s] Banned for cheating\n", accused.Address.String())
+	bannedKeys[report.AccusedKey] = true
+	delete(allPlayers.all, report.AccusedKey)
+
+	return nil
+}
+
 func getSettingsByConfigString(configString string) (shared.GameConfig) {
 	var response shared.GameConfig
 	switch configString {
@@ -233,7 +334,43 @@ func getSettingsByConfigString(configString string) (shared.GameConfig) {
 		response = shared.GameConfig {
 			InitState: 	initState,
 			GlobalServerHB: heartBeat,
+			GameId: 	gameId,
+			Epoch: 		epoch,
+			Ping: 		ping,
+			MoveInterval: moveInterval,
+			InterestRadius: interestRadius,
+			SummaryInterval: summaryInterval,
+			BatchWindow: batchWindow,
+			Lockstep: shared.LockstepNearPrey,
+			LockstepRadius: lockstepRadius,
+			RevealTimeout: revealTimeout,
+			Occupancy: shared.OccupancyBlock,
+			PreyStrategy: "bfs",
+		}
+	case "2":
+		settings := shared.InitialGameSettings {
+			WindowsX: 300,
+			WindowsY: 300,
+			WallCoordinates: []shared.Coord{{X: 4, Y:3}, {X: 9, Y:9}},
+			ScoreboardWidth: 200,
+		}
+
+		initState := shared.InitialState {
+			Settings: settings,
+			CatchWorth: 1,
+		}
+
+		response = shared.GameConfig {
+			InitState: 	initState,
+			GlobalServerHB: heartBeat,
+			GameId: 	gameId,
+			Epoch: 		epoch,
 			Ping: 		ping,
+			MoveInterval: moveInterval,
+			TickInterval: tickInterval,
+			TickDelay: 	tickDelay,
+			DeterministicPrey: true,
+			PreySeed: 	preySeed,
 		}
 	default:
 		settings := shared.InitialGameSettings {
@@ -251,7 +388,10 @@ func getSettingsByConfigString(configString string) (shared.GameConfig) {
 		response = shared.GameConfig {
 			InitState: 	initState,
 			GlobalServerHB: heartBeat,
+			GameId: 	gameId,
+			Epoch: 		epoch,
 			Ping: 		ping,
+			MoveInterval: moveInterval,
 		}
 	}
 
@@ -2,10 +2,55 @@ package shared
 
 import (
 	_ "crypto/ecdsa"
+	"encoding/json"
+	"strconv"
+	"strings"
 	"sync"
 	"net"
 )
 
+// Identifies the type of a message sent between nodes so the receiver knows how to handle it
+type MessageKind string
+
+const (
+	MoveMessage         MessageKind = "move"
+	MoveCommitMessage   MessageKind = "moveCommit"
+	GameStateMessage    MessageKind = "gameState"
+	GameStateReqMessage MessageKind = "gamestateReq"
+	ConnectMessage      MessageKind = "connect"
+	ConnectedMessage    MessageKind = "connected"
+	CapturedMessage     MessageKind = "captured"
+	RejectedMessage     MessageKind = "rejected"
+	AckMessage          MessageKind = "ack"
+	LeaveMessage        MessageKind = "leave"
+	SummaryMessage      MessageKind = "positionSummary"
+	TickHashMessage     MessageKind = "tickHash"
+	LedgerDigestMessage MessageKind = "ledgerDigest"
+	LedgerEntryMessage  MessageKind = "ledgerEntry"
+	StateDigestMessage  MessageKind = "stateDigest"
+	StateRepairMessage  MessageKind = "stateRepair"
+	MoveRejectedMessage MessageKind = "moveRejected"
+	AccusationMessage   MessageKind = "accusation"
+	ConfirmMessage      MessageKind = "captureConfirm"
+	CaptureCertMessage  MessageKind = "captureCert"
+)
+
+// The identifier of the first prey to join a game. The server numbers any more prey after it: "prey-2", "prey-3"...
+const PreyIdentifier = "prey"
+
+// Returns the identifier of the nth prey to join a game, counting from 1
+func PreyId(n int) string {
+	if n <= 1 {
+		return PreyIdentifier
+	}
+	return PreyIdentifier + "-" + strconv.Itoa(n)
+}
+
+// Returns true if identifier belongs to a prey rather than a wolf
+func IsPreyId(identifier string) bool {
+	return identifier == PreyIdentifier || strings.HasPrefix(identifier, PreyIdentifier+"-")
+}
+
 // Coordinates of an element in game
 type Coord struct {
 	X int
@@ -15,11 +60,88 @@ type Coord struct {
 type GameConfig struct {
 	InitState			InitialState
 	Identifier 			string
+	// Unique to each run of the server, so move commits from one game can not be replayed in another
+	GameId				string
 	GlobalServerHB		uint32
 	// Number of times we ping another player before we drop them
 	Ping				uint32
+	// Moves are only sent at full rate to players within this many cells (Manhattan distance) of the mover, and
+	// to the prey; 0 sends every move to every node
+	InterestRadius		int
+	// How often, in milliseconds, players outside the interest radius are sent a summary of a player's position
+	SummaryInterval		uint32
+	// How long, in milliseconds, messages for the same player are held so they can be sent as one packet; 0 sends
+	// every message straight away
+	BatchWindow			uint32
+	// Which moves have to be committed to before they are revealed; see LockstepMode
+	Lockstep			LockstepMode
+	// With LockstepNearPrey, moves that end within this many cells (Manhattan distance) of the prey are in lockstep
+	LockstepRadius		int
+	// How long, in milliseconds, a player has to reveal a move after committing to it
+	RevealTimeout		uint32
+	// The length of a tick in milliseconds; if set, every node applies the moves for each tick together, in the same
+	// order, instead of applying each move as it arrives
+	TickInterval		uint32
+	// How many ticks after the tick a move is sent in it is applied, giving it time to reach every node
+	TickDelay			uint32
+	// When the server started, in nanoseconds since the Unix epoch; tick 0 starts then
+	Epoch				int64
+	// What happens when a wolf moves onto a cell another wolf is standing on
+	Occupancy			OccupancyRule
+	// The shortest average time, in milliseconds, a wolf may take between moves (the pixel client sends at most one
+	// every 200ms); moves that come faster are rejected as cheating. 0 does not limit how fast wolves move.
+	MoveInterval		uint32
+	// How often, in milliseconds, nodes swap digests of their game state to find and repair differences; 0 uses
+	// peer.DEFAULT_DIGEST_INTERVAL
+	DigestInterval		uint32
+	// How much of the prey's and each wolf's move history every node keeps to check captures and moves against;
+	// the zero value uses peer.DefaultWindowPolicies
+	PreyWindow			WindowConfig
+	WolfWindow			WindowConfig
+	// How the prey decides where to move; one of the names in prey/impl PreyStrategies. Empty uses the greedy flee
+	// with some random wandering. The prey can pick another on the command line.
+	PreyStrategy		string
+	// If set while the game is ticking, the prey do not choose their own moves: every node works out each prey's step
+	// at each tick from PreySeed, the tick and the wolves' positions, and checks the moves the prey send against it
+	DeterministicPrey	bool
+	// The seed prey steps are worked out from with DeterministicPrey, published by the server for the whole game
+	PreySeed			int64
+}
+
+// How much of a class of player's move history a node keeps
+type WindowConfig struct {
+	// The number of most recent moves always kept
+	Moves				int
+	// How long, in milliseconds, older moves are kept as well; 0 keeps just the most recent Moves
+	Retain				uint32
 }
 
+// Decides what happens when a wolf moves onto a cell another wolf is already standing on
+type OccupancyRule int
+
+const (
+	// Any number of wolves can stand on the same cell
+	OccupancyStack OccupancyRule = iota
+	// The move is not allowed
+	OccupancyBlock
+	// The move is allowed, but the wolf bounces back to the cell it came from
+	OccupancyBounce
+)
+
+// Selects which moves go through the commit-reveal lockstep protocol: the mover first sends a hash of its move,
+// waits for the other players in lockstep to commit to theirs, and only then sends the move itself. Nobody can choose
+// their move after seeing someone else's, which matters most when wolves are racing each other to the prey.
+type LockstepMode int
+
+const (
+	// Every move is sent straight away
+	LockstepOff LockstepMode = iota
+	// Every wolf move is in lockstep
+	LockstepGlobal
+	// Only wolf moves that end near the prey are in lockstep
+	LockstepNearPrey
+)
+
 // Initial game settings sent out by global server to start the game
 type InitialGameSettings struct {
 	WindowsX			float64
@@ -50,6 +172,9 @@ type GameState struct {
 type PlayerLockMap struct {
 	sync.RWMutex
 	Data map[string]Coord
+	// The sequence number of the move each player's position (and score) came from, so that when game states from
+	// several nodes are merged the newest version of each player wins
+	Seq  map[string]uint64
 }
 
 type ScoresLockMap struct {
@@ -60,7 +185,8 @@ type ScoresLockMap struct {
 // Game state sent from logic node to pixel for rendering
 type GameRenderState struct {
 	PlayerLoc Coord
-	Prey Coord
+	// Every prey's position, by identifier
+	Prey map[string]Coord
 	OtherPlayers map[string]Coord
 	Scores map[string]int
 }
@@ -68,12 +194,95 @@ type GameRenderState struct {
 // Move commitment sent by player, must be ACK'ed by all other players in game
 // before this player can receive all other players' game states
 type MoveCommit struct {
+	// SHA-256 over the game id, the player's identifier, the move's sequence number, the move and a random nonce that
+	// is only sent along with the move
 	MoveHash			[]byte
 	PubKey         		string
 	R					string
 	S					string
 }
 
+// Why a node rejected a player's move. It is signed by the rejecting node and sent back to the player.
+type MoveRejection struct {
+	Rejecter			string
+	Identifier			string
+	Seq					uint64
+	Move				Coord
+	Reason				string
+}
+
+// A node's signed vote to evict a player it caught cheating, with what it saw. A player is evicted once a quorum of
+// the other nodes have accused it.
+type Accusation struct {
+	Witness				string
+	Accused				string
+	// The accused's public key, which the server bans once the player is evicted
+	AccusedKey			string
+	// The witness's evidence, one line for each rule the player broke
+	Evidence			[]string
+	// The witness's signature over AccusationBytes
+	R					string
+	S					string
+}
+
+// Returns the bytes the witness signs for an accusation: everything but the signature
+func AccusationBytes(accusation Accusation) []byte {
+	accusation.R, accusation.S = "", ""
+	arr, _ := json.Marshal(accusation)
+	return arr
+}
+
+// Sent to the server by a node that evicted a cheating player, so the server can ban its key. The accusations are
+// checked by the server too, so a single node can not get another player banned.
+type EvictionReport struct {
+	Accused				string
+	AccusedKey			string
+	Accusations			[]Accusation
+}
+
+// A versioned copy of a node's gamestate, as sent between nodes. If Base is set it is a delta, holding only what
+// changed since the sender's version Base.
+type StateSnapshot struct {
+	Version				uint64
+	Base				uint64
+	Locs				map[string]Coord
+	// The sequence number of the move behind each position in Locs
+	Seqs				map[string]uint64
+	Scores				map[string]int
+	// Players that no longer have a position, with the sequence number of the last move they had when they lost it
+	Removed				map[string]uint64
+}
+
+// A capture of a prey, as recorded (once certified) in every node's capture ledger. The capturer signs the event, and the prey's own
+// signed move at PreySeq is carried along as proof of where the prey was, so any node can check the event without
+// having seen the capture happen.
+type CaptureEvent struct {
+	Capturer			string
+	// The identifier of the prey that was captured
+	Prey				string
+	PreyPos				Coord
+	PreySeq				uint64
+	// The points the capture is worth (the game's CatchWorth)
+	Worth				int
+	// The number of other nodes the capturer knew of; a majority of them must confirm the capture
+	Peers				int
+	// The prey's signed move to PreyPos
+	PreyMove			SignedMove
+	// The capturer's signature over the rest of the event, leaving out Confirmations
+	R					string
+	S					string
+	// Signed confirmations from the nodes that checked the capture. A capture only counts once it has a quorum of
+	// them that includes the prey, which makes the event a capture certificate.
+	Confirmations		[]CaptureConfirmation
+}
+
+// A node's signed confirmation that a capture held up when it checked it
+type CaptureConfirmation struct {
+	Identifier			string
+	R					string
+	S					string
+}
+
 type MoveOp struct {
 	PlayerLoc     		Coord
 	PlayerId			string
@@ -0,0 +1,35 @@
+package test
+
+import (
+	"testing"
+	"fmt"
+	"sort"
+	"../shared"
+)
+
+func TestStateDigestRepair(t *testing.T) {
+	n1 := createBoardNode(shared.GameConfig{}, map[string]shared.Coord{"test3": {1, 1}, "2": {2, 2}, "4": {4, 4}})
+	n2 := createBoardNode(shared.GameConfig{}, map[string]shared.Coord{"test3": {1, 1}, "2": {2, 3}, "5": {5, 5}})
+	n1.Role.GameState().PlayerLocs.Seq = map[string]uint64{"test3": 1, "2": 6, "4": 1}
+	n2.Role.GameState().PlayerLocs.Seq = map[string]uint64{"test3": 1, "2": 4, "5": 1}
+
+	// Only the players that differ are repaired
+	differ := n1.DifferingEntities(n2.StateDigest())
+	sort.Strings(differ)
+	if fmt.Sprint(differ) != "[2 4]" {
+		fmt.Println("Fail, expected players 2 and 4 to differ:", differ)
+		t.Fail()
+	}
+
+	n2.HandleReceivedStateRepair("1", n1.PartialGameState(n1.DifferingEntities(n2.StateDigest())))
+	n1.HandleReceivedStateRepair("2", n2.PartialGameState(n2.DifferingEntities(n1.StateDigest())))
+	if len(n1.DifferingEntities(n2.StateDigest())) != 0 || len(n2.DifferingEntities(n1.StateDigest())) != 0 {
+		fmt.Println("Fail, nodes still differ after repair:", n1.Role.GameState().PlayerLocs.Data,
+			n2.Role.GameState().PlayerLocs.Data)
+		t.Fail()
+	}
+	if n2.Role.GameState().PlayerLocs.Data["2"] != (shared.Coord{2, 2}) {
+		fmt.Println("Fail, repair did not take the newer position")
+		t.Fail()
+	}
+}
@@ -0,0 +1,54 @@
+package test
+
+import (
+	"testing"
+	"fmt"
+	"net"
+	"bytes"
+	"time"
+	key_helpers "../key-helpers"
+	"../peer"
+)
+
+func TestBatchMessagesPerNode(t *testing.T) {
+	pubKey, privKey := key_helpers.GenerateKeys()
+	node := peer.CreateNode(pubKey, privKey, ":8081")
+	node.Config.BatchWindow = 50
+
+	receiver, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		fmt.Println("Fail, could not listen:", err)
+		t.FailNow()
+	}
+	defer receiver.Close()
+	node.OtherNodes["2"] = node.GetClientFromAddrString(receiver.LocalAddr().String())
+
+	go node.ManageOtherNodes()
+	defer node.Close()
+
+	messages := [][]byte{[]byte("first"), []byte("second"), []byte("third")}
+	for _, message := range messages {
+		node.MessagesToSend <- &peer.PendingMessage{Recipient: "2", Message: message}
+	}
+
+	buf := make([]byte, 2048)
+	receiver.SetReadDeadline(time.Now().Add(time.Second))
+	length, _, err := receiver.ReadFromUDP(buf)
+	if err != nil {
+		fmt.Println("Fail, batch was not received:", err)
+		t.FailNow()
+	}
+	for _, message := range messages {
+		if !bytes.Contains(buf[:length], message) {
+			fmt.Printf("Fail, batch %q is missing %q\n", buf[:length], message)
+			t.Fail()
+		}
+	}
+
+	// Everything went out in the one datagram
+	receiver.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if _, _, err := receiver.ReadFromUDP(buf); err == nil {
+		fmt.Println("Fail, messages were sent in more than one datagram")
+		t.Fail()
+	}
+}
@@ -3,6 +3,7 @@ import (
 	"fmt"
 	"testing"
 	n "../logic/impl"
+	"../peer"
 	"net"
 	"../key-helpers"
 	"time"
@@ -27,7 +28,7 @@ func TestHeartbeat(t *testing.T) {
 	node := n.CreateNodeCommInterface(pubKey, privKey, ":8081")
 	go node.ManageOtherNodes()
 
-	addr1, nodeConn := n.StartListenerUDP(":2124")
+	addr1, nodeConn := peer.StartListenerUDP(":2124")
 	node.LocalAddr = addr1
 	go node.RunListener(nodeConn, addr1.String())
 
@@ -38,7 +39,7 @@ func TestHeartbeat(t *testing.T) {
 	node2 := n.CreateNodeCommInterface(pubKey, privKey, ":8081")
 	go node2.ManageOtherNodes()
 
-	addr2, nodeConn2 := n.StartListenerUDP(":2125")
+	addr2, nodeConn2 := peer.StartListenerUDP(":2125")
 	node2.LocalAddr = addr2
 	go node2.RunListener(nodeConn2, addr2.String())
 	_ = node2.ServerRegister()
@@ -222,7 +223,7 @@ func TestPubKey(t *testing.T) {
 	node := n.CreateNodeCommInterface(pubKey1, privKey1, ":8081")
 	go node.ManageOtherNodes()
 
-	addr1, nodeConn := n.StartListenerUDP(":2140")
+	addr1, nodeConn := peer.StartListenerUDP(":2140")
 	node.LocalAddr = addr1
 	go node.RunListener(nodeConn, addr1.String())
 
@@ -233,7 +234,7 @@ func TestPubKey(t *testing.T) {
 	node2 := n.CreateNodeCommInterface(pubKey2, privKey2, ":8081")
 	go node2.ManageOtherNodes()
 
-	addr2, nodeConn2 := n.StartListenerUDP(":2150")
+	addr2, nodeConn2 := peer.StartListenerUDP(":2150")
 	node2.LocalAddr = addr2
 	go node2.RunListener(nodeConn2, addr2.String())
 	node2Id := node2.ServerRegister()
@@ -269,6 +270,73 @@ func TestPubKey(t *testing.T) {
 	serverStart.Process.Kill()
 }
 
+func TestLeave(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 7 * time.Second)
+	defer cancel()
+	serverStart := exec.CommandContext(ctx, "go", "run", "server.go")
+	serverStart.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	serverStart.Dir = "../server"
+	serverStart.Start()
+
+	time.Sleep(2 * time.Second) // give server time to start
+
+	fmt.Println("Testing that a node that leaves is removed straight away")
+	pubKey1, privKey1 := key_helpers.GenerateKeys()
+	node1 := n.CreatePlayerNode(":2160", ":2161", pubKey1, privKey1, ":8081")
+
+	pubKey2, privKey2 := key_helpers.GenerateKeys()
+	node2 := n.CreatePlayerNode(":2170", ":2171", pubKey2, privKey2, ":8081")
+
+	time.Sleep(time.Second)
+
+	if _, ok := node1.GetNodeInterface().OtherNodes[node2.Identifier]; !ok {
+		fmt.Println("Fail, node 1 never connected to node 2")
+		t.Fail()
+	}
+
+	node2.Close()
+	time.Sleep(500*time.Millisecond)
+
+	if _, ok := node1.GetNodeInterface().OtherNodes[node2.Identifier]; ok {
+		fmt.Println("Fail, node 1 still has node 2 after it left")
+		t.Fail()
+	}
+
+	// Closing twice should be harmless
+	node2.Close()
+	node1.Close()
+
+	// Kill after done + all children
+	syscall.Kill(-serverStart.Process.Pid, syscall.SIGKILL)
+	serverStart.Process.Kill()
+}
+
+func TestUnknownMessageKind(t *testing.T) {
+	fmt.Println("Testing that messages with no registered handler are counted")
+	pubKey, privKey := key_helpers.GenerateKeys()
+	node := n.CreateNodeCommInterface(pubKey, privKey, ":8081")
+
+	node.Dispatch(&peer.NodeMessage{MessageType: "howl", Identifier: "1"})
+	if node.UnknownMessageCount() != 1 {
+		fmt.Println("Fail, unknown message was not counted")
+		t.Fail()
+	}
+
+	howled := false
+	node.RegisterHandler("howl", func(message *peer.NodeMessage) {
+		howled = true
+	})
+	node.Dispatch(&peer.NodeMessage{MessageType: "howl", Identifier: "1"})
+	if !howled {
+		fmt.Println("Fail, registered handler was not called")
+		t.Fail()
+	}
+	if node.UnknownMessageCount() != 1 {
+		fmt.Println("Fail, handled message was counted as unknown")
+		t.Fail()
+	}
+}
+
 // TODO: Test node re-joins and has been assigned an identifier - cannot assume that it's
 // connecting from the same IP address
 
@@ -0,0 +1,158 @@
+package test
+
+import (
+	"testing"
+	"fmt"
+	"../peer"
+	"../shared"
+)
+
+func TestCheaterEvictedByQuorum(t *testing.T) {
+	honest := []*peer.Node{createCapturingNode("2"), createCapturingNode("3"), createCapturingNode("4")}
+	cheater := createCapturingNode("5")
+	introduce(append(honest, cheater)...)
+	for _, n := range honest {
+		for _, id := range []string{"2", "3", "4", "5"} {
+			if id != n.Role.Identifier() {
+				n.OtherNodes[id] = nil
+			}
+		}
+	}
+
+	// One piece of evidence is not enough to accuse
+	n1, n2, n3 := honest[0], honest[1], honest[2]
+	n1.RecordCheat("5", peer.InvalidMoveCheat, 1, "move to {-1 0}")
+	if len(n1.Accusations("5")) != 0 {
+		fmt.Println("Fail, player accused on a single piece of evidence")
+		t.Fail()
+	}
+	n1.RecordCheat("5", peer.InvalidMoveCheat, 2, "move to {-2 0}")
+	n1.RecordCheat("5", peer.BadSignatureCheat, 3, "move message not signed with its key")
+	accusations := n1.Accusations("5")
+	if len(accusations) != 1 || accusations[0].Witness != "2" || len(accusations[0].Evidence) != 3 {
+		fmt.Println("Fail, player with enough evidence against it was not accused:", accusations)
+		t.FailNow()
+	}
+
+	// An accusation can not be passed off as another node's
+	forged := accusations[0]
+	forged.Witness = "4"
+	n2.HandleReceivedAccusations("2", []shared.Accusation{forged})
+	if len(n2.Accusations("5")) != 0 {
+		fmt.Println("Fail, forged accusation was counted")
+		t.Fail()
+	}
+
+	// The cheater does not get a vote on its own eviction, and one honest node short of a majority evicts nobody
+	n2.HandleReceivedAccusations("2", accusations)
+	if n2.Evicted("5") {
+		fmt.Println("Fail, player evicted by a single accusation")
+		t.Fail()
+	}
+	for i := 1; i <= peer.CHEATS_TO_ACCUSE; i++ {
+		n2.RecordCheat("5", peer.TeleportCheat, uint64(i), "")
+	}
+	if !n2.Evicted("5") {
+		fmt.Println("Fail, player accused by a majority was not evicted")
+		t.FailNow()
+	}
+	select {
+	case id := <-n2.NodesToDelete:
+		if id != "5" {
+			fmt.Println("Fail, evicted the wrong node:", id)
+			t.Fail()
+		}
+	default:
+		fmt.Println("Fail, evicted player was not removed from OtherNodes")
+		t.Fail()
+	}
+
+	// A node that missed the accusations catches up from the ones passed on by the evicting node
+	n3.HandleReceivedAccusations("3", n2.Accusations("5"))
+	if !n3.Evicted("5") {
+		fmt.Println("Fail, passed on accusations did not evict the player")
+		t.Fail()
+	}
+
+	// Accusations against this node do not evict it here
+	cheater.HandleReceivedAccusations("3", n2.Accusations("5"))
+	if cheater.Evicted("5") {
+		fmt.Println("Fail, node evicted itself")
+		t.Fail()
+	}
+}
+
+func TestDroppedCommitIsNotEvidence(t *testing.T) {
+	n := createCapturingNode("3")
+	wolf := createCapturingNode("2")
+	introduce(n, wolf)
+	n.Config.Lockstep = shared.LockstepGlobal
+
+	// The commit for each of these moves was lost on the way, so none of them can be checked, but the wolf did nothing
+	// wrong
+	for seq := uint64(1); seq <= peer.CHEATS_TO_ACCUSE; seq++ {
+		move := shared.Coord{3, int(seq)}
+		n.Dispatch(&peer.NodeMessage{
+			MessageType: shared.MoveMessage,
+			Identifier:  "2",
+			Move:        wolf.CreateMove(&move),
+			Seq:         seq,
+			Nonce:       peer.NewNonce(),
+		})
+	}
+	if evidence := n.Evidence("2"); len(evidence) != 0 {
+		fmt.Println("Fail, moves with a dropped commit were recorded as evidence:", evidence)
+		t.Fail()
+	}
+	if len(n.Accusations("2")) != 0 {
+		fmt.Println("Fail, wolf was accused over dropped commits")
+		t.Fail()
+	}
+}
+
+func TestForgedMoveIsNotEvidence(t *testing.T) {
+	n := createCapturingNode("3")
+	wolf := createCapturingNode("2")
+	forger := createCapturingNode("4")
+	introduce(n, wolf)
+
+	// Moves put out under the wolf's identifier but not signed with its key say nothing about the wolf
+	for seq := uint64(1); seq <= peer.CHEATS_TO_ACCUSE; seq++ {
+		move := shared.Coord{-1, int(seq)}
+		n.Dispatch(&peer.NodeMessage{
+			MessageType: shared.MoveMessage,
+			Identifier:  "2",
+			Move:        forger.CreateMove(&move),
+			Seq:         seq,
+		})
+	}
+	if evidence := n.Evidence("2"); len(evidence) != 0 {
+		fmt.Println("Fail, moves not signed by the wolf were recorded as evidence against it:", evidence)
+		t.Fail()
+	}
+
+	// Nor do moves from a node whose key is not known
+	n.Dispatch(&peer.NodeMessage{
+		MessageType: shared.MoveMessage,
+		Identifier:  "4",
+		Move:        forger.CreateMove(&shared.Coord{-1, 0}),
+		Seq:         1,
+	})
+	if evidence := n.Evidence("4"); len(evidence) != 0 {
+		fmt.Println("Fail, move from a node with no known key was recorded as evidence:", evidence)
+		t.Fail()
+	}
+
+	// A move off the board that the wolf did sign is
+	n.Dispatch(&peer.NodeMessage{
+		MessageType: shared.MoveMessage,
+		Identifier:  "2",
+		Move:        wolf.CreateMove(&shared.Coord{-1, 0}),
+		Seq:         1,
+	})
+	evidence := n.Evidence("2")
+	if len(evidence) != 1 || evidence[0].Kind != peer.InvalidMoveCheat {
+		fmt.Println("Fail, move off the board was not recorded as evidence:", evidence)
+		t.Fail()
+	}
+}
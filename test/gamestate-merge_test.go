@@ -0,0 +1,55 @@
+package test
+
+import (
+	"testing"
+	"fmt"
+	key "../key-helpers"
+	l "../logic/impl"
+	"../shared"
+)
+
+func snapshotOf(locs map[string]shared.Coord, seqs map[string]uint64, scores map[string]int) *shared.StateSnapshot {
+	return &shared.StateSnapshot{Version: 1, Locs: locs, Seqs: seqs, Scores: scores}
+}
+
+func TestMergeGameStates(t *testing.T) {
+	pub, priv := key.GenerateKeys()
+	n := l.CreateNodeCommInterface(pub, priv, ":8081")
+	n.Config.Identifier = "1"
+	n.PlayerNode = &l.PlayerNode{Identifier: "1",
+		GameState: shared.GameState{
+			PlayerLocs:   shared.PlayerLockMap{Data: map[string]shared.Coord{"1": {1, 1}}},
+			PlayerScores: shared.ScoresLockMap{Data: map[string]int{"1": 0}},
+		},
+	}
+
+	// A stale reply arrives first...
+	n.HandleReceivedGameState("2", snapshotOf(
+		map[string]shared.Coord{"1": {7, 7}, "2": {2, 2}, "3": {3, 3}},
+		map[string]uint64{"1": 9, "2": 4, "3": 1},
+		map[string]int{"2": 1, "3": 0}))
+	// ...then a newer one, which is only newer for player 3
+	n.HandleReceivedGameState("3", snapshotOf(
+		map[string]shared.Coord{"2": {2, 1}, "3": {3, 4}},
+		map[string]uint64{"2": 3, "3": 2},
+		map[string]int{"2": 0, "3": 2}))
+
+	locs := n.PlayerNode.GameState.PlayerLocs.Data
+	scores := n.PlayerNode.GameState.PlayerScores.Data
+	if locs["1"] != (shared.Coord{1, 1}) {
+		fmt.Println("Fail, own position was taken from another node:", locs["1"])
+		t.Fail()
+	}
+	if locs["2"] != (shared.Coord{2, 2}) || scores["2"] != 1 {
+		fmt.Println("Fail, player 2 was overwritten by an older version:", locs["2"], scores["2"])
+		t.Fail()
+	}
+	if locs["3"] != (shared.Coord{3, 4}) || scores["3"] != 2 {
+		fmt.Println("Fail, player 3 was not taken from the newest version:", locs["3"], scores["3"])
+		t.Fail()
+	}
+	if !n.HasGameState {
+		fmt.Println("Fail, node does not have a gamestate after merging replies")
+		t.Fail()
+	}
+}
@@ -0,0 +1,46 @@
+package test
+
+import (
+	"testing"
+	"fmt"
+	l "../logic/impl"
+	"../peer"
+	"../shared"
+)
+
+func TestFarNodes(t *testing.T) {
+	locs := map[string]shared.Coord{
+		"1":    {1, 1},
+		"2":    {2, 3},
+		"3":    {9, 9},
+		"prey": {15, 15},
+	}
+	pn := l.PlayerNode{Identifier: "1",
+		GameState: shared.GameState{PlayerLocs: shared.PlayerLockMap{Data: locs}},
+	}
+	n := &l.NodeCommInterface{
+		Node:       &peer.Node{Config: shared.GameConfig{Identifier: "1", InterestRadius: 3}},
+		PlayerNode: &pn,
+	}
+	n.SetRole(n)
+
+	far := n.FarNodes(shared.Coord{1, 1})
+	if len(far) != 1 || !far["3"] {
+		fmt.Println("Fail, expected only node 3 to be far, got", far)
+		t.Fail()
+	}
+
+	// Moving closer brings a node back into range
+	far = n.FarNodes(shared.Coord{7, 8})
+	if far["3"] || !far["2"] {
+		fmt.Println("Fail, far nodes did not follow the move, got", far)
+		t.Fail()
+	}
+
+	// With no radius every node gets every move
+	n.Config.InterestRadius = 0
+	if far = n.FarNodes(shared.Coord{1, 1}); len(far) != 0 {
+		fmt.Println("Fail, interest management is off but got far nodes", far)
+		t.Fail()
+	}
+}
@@ -0,0 +1,49 @@
+package test
+
+import (
+	"testing"
+	"fmt"
+	"time"
+	"github.com/faiface/pixel"
+	p "../pixel/impl"
+)
+
+func TestRemoteEntityInterpolation(t *testing.T) {
+	h := p.NewEntityHistories(100 * time.Millisecond)
+	start := time.Now()
+	h.Record("2", pixel.V(30, 30), start)
+	h.Record("2", pixel.V(60, 30), start.Add(200*time.Millisecond))
+
+	// Drawn the delay behind, halfway between the two cells it was received on
+	if pos, _ := h.PositionAt("2", start.Add(200*time.Millisecond)); pos != pixel.V(45, 30) {
+		fmt.Println("Fail, remote wolf was not interpolated between cells:", pos)
+		t.Fail()
+	}
+
+	// A short gap past the newest cell is bridged by carrying on the way it was going
+	if pos, _ := h.PositionAt("2", start.Add(400*time.Millisecond)); pos != pixel.V(75, 30) {
+		fmt.Println("Fail, remote wolf was not extrapolated over a short gap:", pos)
+		t.Fail()
+	}
+
+	// A long gap leaves it on the newest cell
+	if pos, _ := h.PositionAt("2", start.Add(time.Second)); pos != pixel.V(60, 30) {
+		fmt.Println("Fail, remote wolf was extrapolated too far:", pos)
+		t.Fail()
+	}
+
+	// The prey respawning is jumped to, not slid across the board
+	h.Record("prey", pixel.V(30, 30), start)
+	h.Record("prey", pixel.V(300, 300), start.Add(200*time.Millisecond))
+	if pos, _ := h.PositionAt("prey", start.Add(200*time.Millisecond)); pos != pixel.V(30, 30) {
+		fmt.Println("Fail, prey was slid across the board:", pos)
+		t.Fail()
+	}
+
+	// Entities that are no longer in the game state are forgotten
+	h.Keep(map[string]bool{"prey": true})
+	if _, ok := h.PositionAt("2", start); ok {
+		fmt.Println("Fail, wolf that left is still drawn")
+		t.Fail()
+	}
+}
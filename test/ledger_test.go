@@ -0,0 +1,232 @@
+package test
+
+import (
+	"testing"
+	"fmt"
+	"../peer"
+	"../shared"
+	"../wolferrors"
+)
+
+func createCapturingNode(id string) *peer.Node {
+	n := createBoardNode(shared.GameConfig{GameId: "ledger", InitState: shared.InitialState{CatchWorth: 1}},
+		make(map[string]shared.Coord))
+	n.Role.(*boardRole).id = id
+	return n
+}
+
+// Gives every node the public keys of all the others
+func introduce(nodes ...*peer.Node) {
+	for _, n := range nodes {
+		for _, other := range nodes {
+			n.NodeKeys[other.Role.Identifier()] = other.PubKey
+		}
+	}
+}
+
+// Puts capturer on pos in the game state of each of nodes, as if they had seen it move there
+func seenAt(capturer *peer.Node, pos shared.Coord, nodes ...*peer.Node) {
+	for _, n := range nodes {
+		n.Role.GameState().PlayerLocs.Data[capturer.Role.Identifier()] = pos
+	}
+}
+
+// Has capturer capture prey at pos for preySeq, and has the prey and then each of confirmers, having seen the capturer
+// there, confirm it.
+// Returns the capture certificate, or nil if the confirmations did not make up a quorum
+func certifiedCapture(capturer *peer.Node, prey *peer.Node, pos shared.Coord, preySeq uint64,
+	confirmers ...*peer.Node) (*shared.CaptureEvent, error) {
+	preyId := prey.Role.Identifier()
+	capturer.Ledger.RememberPreyMove(preyId, preySeq, prey.CreateMove(&pos))
+	event, err := capturer.ProposeCapture(preyId, pos, preySeq)
+	if err != nil {
+		return nil, err
+	}
+	var certificate *shared.CaptureEvent
+	for _, confirmer := range append([]*peer.Node{prey}, confirmers...) {
+		seenAt(capturer, pos, confirmer)
+		confirmation, err := confirmer.ConfirmCapture(event)
+		if err != nil {
+			return nil, err
+		}
+		if c := capturer.HandleReceivedCaptureConfirmation(confirmer.Role.Identifier(), preyId, preySeq, confirmation); c != nil {
+			certificate = c
+		}
+	}
+	return certificate, nil
+}
+
+func TestLedgerConverges(t *testing.T) {
+	n1 := createCapturingNode("2")
+	n2 := createCapturingNode("3")
+	joiner := createCapturingNode("4")
+	prey := createCapturingNode("prey")
+	introduce(n1, n2, joiner, prey)
+
+	// Each wolf captures the prey at a different prey sequence number
+	e1, err1 := certifiedCapture(n1, prey, shared.Coord{3, 3}, 1)
+	e2, err2 := certifiedCapture(n2, prey, shared.Coord{4, 4}, 2)
+	if e1 == nil || e2 == nil {
+		fmt.Println("Fail, could not record captures:", err1, err2)
+		t.FailNow()
+	}
+
+	// A capture that was tampered with after it was signed is not added
+	forged := *e2
+	forged.Capturer = "2"
+	if err := n1.CheckCapture(&forged); err == nil {
+		fmt.Println("Fail, capture signed by another wolf was accepted")
+		t.Fail()
+	}
+
+	// Nor is one by a wolf whose key the node does not have, since its signature can not be checked
+	stranger := createCapturingNode("5")
+	stranger.NodeKeys["prey"] = prey.PubKey
+	if err := stranger.VerifyCaptureEvent(e1); err == nil {
+		fmt.Println("Fail, capture by a wolf with no known key was accepted")
+		t.Fail()
+	}
+
+	// n1 hears about n2's capture directly, and the joiner only catches up through reconciling with n2
+	n1.CheckCapture(e2)
+	n2.CheckCapture(e1)
+	missing := n2.Ledger.Missing(joiner.Ledger.Ids())
+	if len(missing) != 2 {
+		fmt.Println("Fail, expected both captures to be missing from the joiner's ledger:", len(missing))
+		t.Fail()
+	}
+	joiner.HandleReceivedLedgerEntries("3", []shared.CaptureEvent{missing[1], missing[0]})
+	if len(n1.Ledger.Missing(joiner.Ledger.Ids())) != 0 {
+		fmt.Println("Fail, ledgers did not converge")
+		t.Fail()
+	}
+
+	for _, n := range []*peer.Node{n1, n2, joiner} {
+		scores := n.Role.GameState().PlayerScores.Data
+		if scores["2"] != 1 || scores["3"] != 1 {
+			fmt.Println("Fail, scores were not derived from the ledger:", scores)
+			t.Fail()
+		}
+	}
+
+	// Getting the same capture again changes nothing
+	joiner.HandleReceivedLedgerEntries("2", []shared.CaptureEvent{*e1})
+	if len(joiner.Ledger.Entries()) != 2 || joiner.Role.GameState().PlayerScores.Data["2"] != 1 {
+		fmt.Println("Fail, repeated capture was counted twice")
+		t.Fail()
+	}
+}
+
+func TestCaptureNeedsQuorumIncludingPrey(t *testing.T) {
+	capturer := createCapturingNode("2")
+	wolves := []*peer.Node{createCapturingNode("3"), createCapturingNode("4"), createCapturingNode("5")}
+	prey := createCapturingNode("prey")
+	introduce(append(wolves, capturer, prey)...)
+	// The capturer knows of four other nodes, so it needs three confirmations
+	for _, id := range []string{"3", "4", "5", "prey"} {
+		capturer.OtherNodes[id] = nil
+	}
+
+	// Without the prey's confirmation there is no certificate, however many wolves confirm
+	pos := shared.Coord{3, 3}
+	seenAt(capturer, pos, wolves...)
+	capturer.Ledger.RememberPreyMove("prey", 1, prey.CreateMove(&pos))
+	event, err := capturer.ProposeCapture("prey", pos, 1)
+	if err != nil {
+		fmt.Println("Fail, could not propose capture:", err)
+		t.FailNow()
+	}
+	for _, wolf := range wolves {
+		confirmation, _ := wolf.ConfirmCapture(event)
+		if capturer.HandleReceivedCaptureConfirmation(wolf.Role.Identifier(), "prey", 1, confirmation) != nil {
+			fmt.Println("Fail, capture was certified without the prey")
+			t.Fail()
+		}
+	}
+	if _, ok := wolves[0].CheckCapture(event).(wolferrors.UncertifiedCaptureError); !ok {
+		fmt.Println("Fail, capture without confirmations was added to the ledger")
+		t.Fail()
+	}
+
+	// The prey and one wolf are not a quorum of four; the prey and two wolves are
+	certificate, err := certifiedCapture(capturer, prey, pos, 2, wolves[0])
+	if certificate != nil || err != nil {
+		fmt.Println("Fail, capture was certified by too few nodes:", err)
+		t.Fail()
+	}
+	certificate, err = certifiedCapture(capturer, prey, pos, 3, wolves[0], wolves[1])
+	if certificate == nil || err != nil {
+		fmt.Println("Fail, capture confirmed by a quorum was not certified:", err)
+		t.FailNow()
+	}
+
+	// A node that joins later checks the certificate against the public keys it has stored
+	joiner := createCapturingNode("6")
+	introduce(append(wolves, capturer, prey, joiner)...)
+	if err := joiner.CheckCapture(certificate); err != nil {
+		fmt.Println("Fail, joining node rejected a valid certificate:", err)
+		t.Fail()
+	}
+	forged := *certificate
+	forged.Confirmations = append([]shared.CaptureConfirmation{}, certificate.Confirmations...)
+	forged.Confirmations[0].R = forged.Confirmations[1].R
+	other := createCapturingNode("7")
+	introduce(append(wolves, capturer, prey, other)...)
+	if err := other.CheckCapture(&forged); err == nil {
+		fmt.Println("Fail, certificate with a forged confirmation was accepted")
+		t.Fail()
+	}
+}
+
+func TestCaptureConfirmedWhereCapturerWasSeen(t *testing.T) {
+	capturer := createCapturingNode("2")
+	confirmer := createCapturingNode("3")
+	prey := createCapturingNode("prey")
+	introduce(capturer, confirmer, prey)
+
+	pos := shared.Coord{3, 3}
+	capturer.Ledger.RememberPreyMove("prey", 1, prey.CreateMove(&pos))
+	event, err := capturer.ProposeCapture("prey", pos, 1)
+	if err != nil {
+		fmt.Println("Fail, could not propose capture:", err)
+		t.FailNow()
+	}
+
+	// A capture by a wolf the node has somewhere else is not confirmed, and is evidence against it
+	seenAt(capturer, shared.Coord{7, 7}, confirmer)
+	if _, err := confirmer.ConfirmCapture(event); err == nil {
+		fmt.Println("Fail, capture by a wolf seen elsewhere was confirmed")
+		t.Fail()
+	}
+	confirmer.HandleCaptureRequest("2", event)
+	evidence := confirmer.Evidence("2")
+	if len(evidence) != 1 || evidence[0].Kind != peer.ScoreCheat {
+		fmt.Println("Fail, capture by a wolf seen elsewhere was not recorded as evidence:", evidence)
+		t.Fail()
+	}
+	seenAt(capturer, pos, confirmer)
+	if _, err := confirmer.ConfirmCapture(event); err != nil {
+		fmt.Println("Fail, capture by a wolf seen on the prey was not confirmed:", err)
+		t.Fail()
+	}
+
+	// Nodes joining while the capture went on is reason to reject it, but not evidence of cheating
+	joined := createCapturingNode("4")
+	introduce(capturer, joined, prey)
+	for _, id := range []string{"5", "6", "prey"} {
+		joined.OtherNodes[id] = nil
+	}
+	seenAt(capturer, pos, joined)
+	if _, err := joined.ConfirmCapture(event); err == nil {
+		fmt.Println("Fail, capture made knowing of too few nodes was confirmed")
+		t.Fail()
+	} else if _, ok := err.(wolferrors.CapturePeersError); !ok {
+		fmt.Println("Fail, capture made knowing of too few nodes was rejected for another reason:", err)
+		t.Fail()
+	}
+	joined.HandleCaptureRequest("2", event)
+	if evidence := joined.Evidence("2"); len(evidence) != 0 {
+		fmt.Println("Fail, capture rejected for nodes joining was recorded as evidence:", evidence)
+		t.Fail()
+	}
+}
@@ -4,12 +4,16 @@ import (
 	"testing"
 	"fmt"
 	_"context"
-	_"time"
+	"time"
+	"net"
 	_"os/exec"
 	_"syscall"
 	key "../key-helpers"
 	l "../logic/impl"
+	"../peer"
 	"../shared"
+	"../wolferrors"
+	"../geometry"
 	"encoding/hex"
 )
 
@@ -18,12 +22,11 @@ func TestHashAndSigning (t *testing.T) {
 	pn := l.PlayerNode{Identifier: "test1",
 	}
 	n := l.NodeCommInterface {
+		Node: &peer.Node{PubKey: pub, PrivKey: priv},
 		PlayerNode: &pn,
-		PubKey: pub,
-		PrivKey: priv,
 	}
 
-	hashStr := n.CalculateHash(shared.Coord{8,9}, n.PlayerNode.Identifier)
+	hashStr := n.CalculateHash(shared.Coord{8,9}, n.PlayerNode.Identifier, 1, peer.NewNonce())
 	r, s, err := n.SignMoveCommit(hashStr)
 	if err != nil {
 		fmt.Println("Something went wrong with signing move commit")
@@ -49,16 +52,16 @@ func TestCheckMoveCommitAgainstMove (t *testing.T) {
 	pn := l.PlayerNode{Identifier: "test2",
 	}
 	n := l.NodeCommInterface {
+		Node: &peer.Node{PubKey: pub, PrivKey: priv},
 		PlayerNode: &pn,
-		PubKey: pub,
-		PrivKey: priv,
 	}
 	testCoords := shared.Coord{8,9}
-	hashStr := n.CalculateHash(testCoords, n.PlayerNode.Identifier)
+	nonce := peer.NewNonce()
+	hashStr := n.CalculateHash(testCoords, n.PlayerNode.Identifier, 1, nonce)
 	n.MoveCommits = make(map[string]string)
 	n.MoveCommits["test2"] = hex.EncodeToString(hashStr)
 
-	if !n.CheckMoveCommitAgainstMove("test2", testCoords) {
+	if !n.CheckMoveCommitAgainstMove("test2", testCoords, 1, nonce) {
 		fmt.Println("There is no move associated with a move commit in n.MoveCommits map")
 		t.Fail()
 	}
@@ -69,19 +72,102 @@ func TestCheckMoveCommitAgainstMoveInvalid (t *testing.T) {
 	pn := l.PlayerNode{Identifier: "test2",
 	}
 	n := l.NodeCommInterface {
+		Node: &peer.Node{PubKey: pub, PrivKey: priv},
 		PlayerNode: &pn,
-		PubKey: pub,
-		PrivKey: priv,
 	}
 	testCoords := shared.Coord{8,9}
-	hashStr := n.CalculateHash(testCoords, n.PlayerNode.Identifier)
+	nonce := peer.NewNonce()
+	hashStr := n.CalculateHash(testCoords, n.PlayerNode.Identifier, 1, nonce)
 	n.MoveCommits = make(map[string]string)
 	n.MoveCommits["test2"] = hex.EncodeToString(hashStr)
 
-	if n.CheckMoveCommitAgainstMove("SoMeOtHErId", testCoords) {
+	if n.CheckMoveCommitAgainstMove("SoMeOtHErId", testCoords, 1, nonce) {
 		fmt.Println("There should not be a matching hash in n.MoveCommits map")
 		t.Fail()
 	}
+	if n.CheckMoveCommitAgainstMove("test2", testCoords, 2, nonce) {
+		fmt.Println("A commit should not match a move with another sequence number")
+		t.Fail()
+	}
+	if n.CheckMoveCommitAgainstMove("test2", testCoords, 1, peer.NewNonce()) {
+		fmt.Println("A commit should not match a move revealed with another nonce")
+		t.Fail()
+	}
+}
+
+// A wolf with nothing but a game state, on a board with no walls
+type boardRole struct {
+	// The node's identifier; "test3" if not set
+	id        string
+	gameState shared.GameState
+	geo       geometry.GridManager
+}
+
+func (r *boardRole) Identifier() string {
+	if r.id == "" {
+		return "test3"
+	}
+	return r.id
+}
+func (r *boardRole) IsPrey() bool { return false }
+func (r *boardRole) GameState() *shared.GameState { return &r.gameState }
+func (r *boardRole) GetGridManager() *geometry.GridManager { return &r.geo }
+func (r *boardRole) GameStateChanged() {}
+func (r *boardRole) RoleHandlers() map[shared.MessageKind]peer.MessageHandler { return nil }
+
+func TestLockstepReveal (t *testing.T) {
+	pub, priv := key.GenerateKeys()
+	n := peer.CreateNode(pub, priv, ":8081")
+	n.LocalAddr = &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 15700}
+	n.Config = shared.GameConfig{Identifier: "test3", Lockstep: shared.LockstepGlobal, RevealTimeout: 50}
+	n.SetRole(&boardRole{
+		gameState: shared.GameState{PlayerLocs: shared.PlayerLockMap{Data: make(map[string]shared.Coord)}},
+		geo:       geometry.CreateNewGridManager(shared.InitialGameSettings{WindowsX: 300, WindowsY: 300}),
+	})
+	_, pubStr := key.Encode(priv, pub)
+	n.NodeKeys["test4"] = pub
+	nonce := peer.NewNonce()
+	commit := func(move shared.Coord, seq uint64) {
+		hash := n.CalculateHash(move, "test4", seq, nonce)
+		r, s, _ := n.SignMoveCommit(hash)
+		n.HandleReceivedMoveCommit("test4", &shared.MoveCommit{MoveHash: hash, PubKey: pubStr, R: r.String(), S: s.String()})
+	}
+
+	// A commit signed with some other key can not take the player's commit slot
+	otherPub, otherPriv := key.GenerateKeys()
+	other := peer.CreateNode(otherPub, otherPriv, ":8081")
+	_, otherPubStr := key.Encode(otherPriv, otherPub)
+	hash := other.CalculateHash(shared.Coord{1,1}, "test4", 1, nonce)
+	r, s, _ := other.SignMoveCommit(hash)
+	forged := &shared.MoveCommit{MoveHash: hash, PubKey: otherPubStr, R: r.String(), S: s.String()}
+	if _, ok := n.HandleReceivedMoveCommit("test4", forged).(wolferrors.IncorrectPlayerError); !ok {
+		fmt.Println("Fail, commit signed with another player's key was taken")
+		t.Fail()
+	}
+
+	if !n.InLockstep("test4", shared.Coord{8,9}) || n.InLockstep("prey", shared.Coord{8,9}) {
+		fmt.Println("Fail, only wolf moves should be in lockstep")
+		t.Fail()
+	}
+
+	commit(shared.Coord{8,9}, 1)
+	if err := n.HandleReceivedMoveL("test4", &shared.Coord{8,9}, 1, nonce); err != nil {
+		fmt.Println("Fail, reveal matching the commit was rejected:", err)
+		t.Fail()
+	}
+
+	commit(shared.Coord{8,9}, 2)
+	if _, ok := n.HandleReceivedMoveL("test4", &shared.Coord{9,9}, 2, nonce).(wolferrors.MoveCommitMismatchError); !ok {
+		fmt.Println("Fail, reveal not matching the commit was accepted")
+		t.Fail()
+	}
+
+	commit(shared.Coord{8,9}, 3)
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := n.HandleReceivedMoveL("test4", &shared.Coord{8,9}, 3, nonce).(wolferrors.LateRevealError); !ok {
+		fmt.Println("Fail, late reveal was accepted")
+		t.Fail()
+	}
 }
 
 // Commenting out because we're not sending move commits now
@@ -0,0 +1,50 @@
+package test
+
+import (
+	"testing"
+	"fmt"
+	"time"
+	"../peer"
+	"../shared"
+	"../wolferrors"
+)
+
+func TestMoveRateLimit(t *testing.T) {
+	n := createBoardNode(shared.GameConfig{MoveInterval: 20}, make(map[string]shared.Coord))
+
+	// A wolf moving at the allowed rate is never stopped
+	for seq := uint64(1); seq <= 6; seq++ {
+		if err := n.HandleReceivedMoveNL("2", &shared.Coord{1, int(seq)}, seq); err != nil {
+			fmt.Println("Fail, move at the allowed rate was rejected:", err)
+			t.Fail()
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// A speed hacked wolf sends valid one step moves back to back
+	var err error
+	for seq := uint64(1); seq <= peer.MOVE_RATE_MIN_MOVES; seq++ {
+		err = n.HandleReceivedMoveNL("4", &shared.Coord{2, int(seq)}, seq)
+	}
+	if _, ok := err.(wolferrors.MoveTooFastError); !ok {
+		fmt.Println("Fail, moves sent back to back were accepted:", err)
+		t.Fail()
+	}
+	evidence := n.Evidence("4")
+	if len(evidence) != 1 || evidence[0].Kind != peer.SpeedCheat || evidence[0].Seq != peer.MOVE_RATE_MIN_MOVES {
+		fmt.Println("Fail, speed hack was not recorded as evidence:", evidence)
+		t.Fail()
+	}
+	if len(n.Evidence("2")) != 0 {
+		fmt.Println("Fail, evidence recorded against an honest wolf")
+		t.Fail()
+	}
+
+	// The prey is not limited
+	for seq := uint64(1); seq <= 2*peer.MOVE_RATE_MIN_MOVES; seq++ {
+		if err := n.HandleReceivedMoveNL("prey", &shared.Coord{3, int(seq)}, seq); err != nil {
+			fmt.Println("Fail, prey move was rejected:", err)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package test
+
+import (
+	"testing"
+	"fmt"
+	"../peer"
+	"../shared"
+)
+
+func TestPreyIds(t *testing.T) {
+	if shared.PreyId(1) != "prey" || shared.PreyId(3) != "prey-3" {
+		fmt.Println("Fail, prey numbered wrong:", shared.PreyId(1), shared.PreyId(3))
+		t.Fail()
+	}
+	for _, id := range []string{"prey", "prey-2", "prey-12"} {
+		if !shared.IsPreyId(id) {
+			fmt.Println("Fail, not taken for a prey:", id)
+			t.Fail()
+		}
+	}
+	for _, id := range []string{"2", "preyx", ""} {
+		if shared.IsPreyId(id) {
+			fmt.Println("Fail, taken for a prey:", id)
+			t.Fail()
+		}
+	}
+}
+
+func TestCheckGotEachPrey(t *testing.T) {
+	n := createBoardNode(shared.GameConfig{},
+		map[string]shared.Coord{"2": {3, 3}, "prey": {1, 1}, "prey-2": {4, 4}, "prey-3": {4, 4}})
+	if prey, err := n.CheckGotPrey(shared.Coord{1, 1}); prey != "prey" || err != nil {
+		fmt.Println("Fail, did not catch the first prey:", prey, err)
+		t.Fail()
+	}
+	if prey, err := n.CheckGotPrey(shared.Coord{4, 4}); prey != "prey-2" || err != nil {
+		fmt.Println("Fail, did not catch the lowest prey on a shared cell:", prey, err)
+		t.Fail()
+	}
+	if prey, err := n.CheckGotPrey(shared.Coord{3, 3}); err == nil {
+		fmt.Println("Fail, caught a wolf:", prey)
+		t.Fail()
+	}
+}
+
+func TestCapturesPerPrey(t *testing.T) {
+	n1 := createCapturingNode("2")
+	n2 := createCapturingNode("3")
+	prey := createCapturingNode("prey")
+	prey2 := createCapturingNode("prey-2")
+	introduce(n1, n2, prey, prey2)
+
+	// Both prey are caught at their sequence number 1; the captures do not tie with each other
+	e1, err1 := certifiedCapture(n1, prey, shared.Coord{3, 3}, 1)
+	e2, err2 := certifiedCapture(n2, prey2, shared.Coord{5, 5}, 1)
+	if e1 == nil || e2 == nil {
+		fmt.Println("Fail, could not capture both prey:", err1, err2)
+		t.FailNow()
+	}
+	if peer.CaptureId(e1) == peer.CaptureId(e2) || e2.Prey != "prey-2" {
+		fmt.Println("Fail, captures of different prey were not told apart:", peer.CaptureId(e1), peer.CaptureId(e2))
+		t.Fail()
+	}
+	n1.CheckCapture(e2)
+	scores := n1.Role.GameState().PlayerScores.Data
+	if scores["2"] != 1 || scores["3"] != 1 {
+		fmt.Println("Fail, captures of different prey at the same sequence number did not both count:", scores)
+		t.Fail()
+	}
+
+	// Only the captured prey's own confirmation certifies a capture of it
+	pos := shared.Coord{6, 6}
+	n1.Ledger.RememberPreyMove("prey-2", 2, prey2.CreateMove(&pos))
+	event, err := n1.ProposeCapture("prey-2", pos, 2)
+	if err != nil {
+		fmt.Println("Fail, could not propose capture:", err)
+		t.FailNow()
+	}
+	seenAt(n1, pos, prey)
+	confirmation, err := prey.ConfirmCapture(event)
+	if err != nil {
+		fmt.Println("Fail, other prey did not confirm the capture:", err)
+		t.FailNow()
+	}
+	if n1.HandleReceivedCaptureConfirmation("prey", "prey-2", 2, confirmation) != nil {
+		fmt.Println("Fail, capture was certified without the captured prey")
+		t.Fail()
+	}
+
+	// A capture of one prey can not be passed off with another prey's signed move
+	moved := shared.Coord{7, 7}
+	n1.Ledger.RememberPreyMove("prey-2", 3, prey.CreateMove(&moved))
+	seenAt(n1, moved, n2)
+	if forged, err := n1.ProposeCapture("prey-2", moved, 3); err == nil {
+		if _, err := n2.ConfirmCapture(forged); err == nil {
+			fmt.Println("Fail, capture proven with another prey's move was confirmed")
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package test
+
+import (
+	"testing"
+	"fmt"
+	"net"
+	key "../key-helpers"
+	"../peer"
+	"../shared"
+	"../geometry"
+	"../wolferrors"
+)
+
+func createBoardNode(config shared.GameConfig, locs map[string]shared.Coord) *peer.Node {
+	pub, priv := key.GenerateKeys()
+	n := peer.CreateNode(pub, priv, ":8081")
+	n.LocalAddr = &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 15702}
+	n.Config = config
+	n.SetRole(&boardRole{
+		gameState: shared.GameState{
+			PlayerLocs:   shared.PlayerLockMap{Data: locs},
+			PlayerScores: shared.ScoresLockMap{Data: make(map[string]int)},
+		},
+		geo: geometry.CreateNewGridManager(shared.InitialGameSettings{WindowsX: 300, WindowsY: 300}),
+	})
+	return n
+}
+
+func TestOccupancyRules(t *testing.T) {
+	block := createBoardNode(shared.GameConfig{Occupancy: shared.OccupancyBlock},
+		map[string]shared.Coord{"2": {3, 3}, "4": {3, 4}})
+	if _, ok := block.HandleReceivedMoveNL("4", &shared.Coord{3, 3}, 1).(wolferrors.CellOccupiedError); !ok {
+		fmt.Println("Fail, move onto another wolf was not blocked")
+		t.Fail()
+	}
+	if err := block.HandleReceivedMoveNL("prey", &shared.Coord{3, 3}, 1); err != nil {
+		fmt.Println("Fail, prey was blocked by a wolf:", err)
+		t.Fail()
+	}
+
+	bounce := createBoardNode(shared.GameConfig{Occupancy: shared.OccupancyBounce},
+		map[string]shared.Coord{"2": {3, 3}, "4": {3, 4}})
+	// The mover is told it bounced, rather than ACKed, so it goes back to where it was too
+	if _, ok := bounce.HandleReceivedMoveNL("4", &shared.Coord{3, 3}, 1).(wolferrors.BouncedMoveError); !ok {
+		fmt.Println("Fail, bounced move was not rejected")
+		t.Fail()
+	}
+	if bounce.Role.GameState().PlayerLocs.Data["4"] != (shared.Coord{3, 4}) {
+		fmt.Println("Fail, wolf did not bounce back")
+		t.Fail()
+	}
+	if _, ok := bounce.RW.PositionAt("4", 1); ok {
+		fmt.Println("Fail, bounced move was added to the running window")
+		t.Fail()
+	}
+	if err := bounce.HandleReceivedMoveNL("4", &shared.Coord{4, 4}, 2); err != nil {
+		fmt.Println("Fail, move from where the wolf bounced back to was rejected:", err)
+		t.Fail()
+	}
+
+	stack := createBoardNode(shared.GameConfig{}, map[string]shared.Coord{"2": {3, 3}, "4": {3, 4}})
+	if err := stack.HandleReceivedMoveNL("4", &shared.Coord{3, 3}, 1); err != nil {
+		fmt.Println("Fail, wolves could not stack:", err)
+		t.Fail()
+	}
+}
+
+func TestCaptureTieBreak(t *testing.T) {
+	prey := shared.Coord{3, 3}
+	n1 := createCapturingNode("2")
+	n2 := createCapturingNode("3")
+	preyNode := createCapturingNode("prey")
+	introduce(n1, n2, preyNode)
+
+	// Both wolves caught the prey at the same prey sequence number; each node hears about the other's capture after
+	// certifying its own
+	e1, _ := certifiedCapture(n1, preyNode, prey, 7)
+	e2, _ := certifiedCapture(n2, preyNode, prey, 7)
+	if e1 == nil || e2 == nil {
+		fmt.Println("Fail, could not certify captures")
+		t.FailNow()
+	}
+	n1.CheckCapture(e2)
+	n2.CheckCapture(e1)
+
+	winner := peer.CaptureTieBreak(7, "2", "3")
+	if winner != peer.CaptureTieBreak(7, "3", "2") {
+		fmt.Println("Fail, tie break depends on the order of the wolves")
+		t.Fail()
+	}
+	for _, n := range []*peer.Node{n1, n2} {
+		scores := n.Role.GameState().PlayerScores.Data
+		if scores[winner] != 1 || scores["2"]+scores["3"] != 1 {
+			fmt.Println("Fail, capture did not go to the tie break winner:", scores)
+			t.Fail()
+		}
+	}
+
+	// The same wolf can not capture the prey twice at the same prey sequence number
+	winnerNode := n1
+	if winner == "3" {
+		winnerNode = n2
+	}
+	if _, err := winnerNode.ProposeCapture("prey", prey, 7); err == nil {
+		fmt.Println("Fail, second capture at the same prey sequence number was accepted")
+		t.Fail()
+	}
+}
@@ -0,0 +1,64 @@
+package test
+
+import (
+	"testing"
+	"fmt"
+	"time"
+	l "../logic/impl"
+	"../shared"
+)
+
+func TestPendingMovesSettle(t *testing.T) {
+	members := map[string]bool{"2": true, "3": true, "4": true, "5": true}
+	isMember := func(id string) bool { return members[id] }
+	now := time.Now()
+	pending := l.NewPendingMoves()
+	pending.Add(1, shared.Coord{1, 1}, nil, now.Add(time.Second))
+	pending.Add(2, shared.Coord{1, 2}, map[string]bool{"5": true}, now.Add(time.Second))
+
+	// An ACK only counts for the sequence number it is for
+	pending.Ack(2, "2")
+	pending.Ack(2, "3")
+	if settled := pending.Settle(len(members), isMember, now); len(settled) != 1 || settled[0].Seq != 2 ||
+		settled[0].Outcome != l.MoveCommitted {
+		fmt.Println("Fail, move ACKed by two of the three nodes it went to was not committed:", settled)
+		t.Fail()
+	}
+	if pending.Ack(2, "4") {
+		fmt.Println("Fail, ACK for a settled move was taken")
+		t.Fail()
+	}
+
+	// Move 1 went to four nodes and needs three ACKs, until a node leaves
+	pending.Ack(1, "2")
+	pending.Ack(1, "3")
+	if settled := pending.Settle(len(members), isMember, now); len(settled) != 0 {
+		fmt.Println("Fail, move committed without a quorum:", settled)
+		t.Fail()
+	}
+	delete(members, "5")
+	if settled := pending.Settle(len(members), isMember, now); len(settled) != 1 || settled[0].Outcome != l.MoveCommitted {
+		fmt.Println("Fail, quorum was not worked out from the current membership:", settled)
+		t.Fail()
+	}
+
+	// A move that is not ACKed in time fails
+	pending.Add(3, shared.Coord{1, 3}, nil, now.Add(time.Second))
+	pending.Ack(3, "4")
+	if settled := pending.Settle(len(members), isMember, now.Add(time.Second)); len(settled) != 1 ||
+		settled[0].Outcome != l.MoveFailed {
+		fmt.Println("Fail, move past its deadline did not fail:", settled)
+		t.Fail()
+	}
+	if pending.Len() != 0 {
+		fmt.Println("Fail, settled moves are still pending")
+		t.Fail()
+	}
+
+	// A node on its own commits its moves straight away
+	pending.Add(4, shared.Coord{1, 4}, nil, now.Add(time.Second))
+	if settled := pending.Settle(0, isMember, now); len(settled) != 1 || settled[0].Outcome != l.MoveCommitted {
+		fmt.Println("Fail, move with no one to ACK it was not committed:", settled)
+		t.Fail()
+	}
+}
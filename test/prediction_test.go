@@ -0,0 +1,87 @@
+package test
+
+import (
+	"testing"
+	"fmt"
+	l "../logic/impl"
+	"../shared"
+)
+
+// Steps one cell in the direction of input on an open board with a wall along x = 3
+func stepOpenBoard(from shared.Coord, input string) (shared.Coord, bool) {
+	switch input {
+	case "up":
+		from.Y++
+	case "down":
+		from.Y--
+	case "left":
+		from.X--
+	case "right":
+		from.X++
+	}
+	return from, from.X != 3
+}
+
+func TestPredictionRollsBackAndReplays(t *testing.T) {
+	p := l.NewPrediction(shared.Coord{1, 1})
+	p.Lock()
+	defer p.Unlock()
+	p.Predict(1, shared.Coord{1, 2}, []string{"up"})
+	p.Predict(2, shared.Coord{2, 2}, []string{"right"})
+	p.Predict(3, shared.Coord{2, 3}, []string{"up"})
+
+	// Committing a move settles the ones before it too
+	if !p.Commit(2) || len(p.Unconfirmed()) != 1 {
+		fmt.Println("Fail, committed moves are still predicted:", p.Unconfirmed())
+		t.Fail()
+	}
+	if p.Commit(1) {
+		fmt.Println("Fail, move settled by a later commit was committed again")
+		t.Fail()
+	}
+
+	// Moves 4 and 5 are made on top of move 3; when it fails, their inputs are replayed from where move 2 left the
+	// wolf, and the one that now runs into the wall is dropped
+	p.Predict(4, shared.Coord{2, 4}, []string{"up"})
+	p.Predict(5, shared.Coord{3, 4}, []string{"right"})
+	pos, replayed, ok := p.Fail(3, stepOpenBoard)
+	if !ok || pos != (shared.Coord{2, 3}) || len(replayed) != 1 || replayed[0] != "up" {
+		fmt.Println("Fail, wrong replay after a rollback:", pos, replayed)
+		t.Fail()
+	}
+	if len(p.Unconfirmed()) != 0 {
+		fmt.Println("Fail, rolled back moves are still predicted:", p.Unconfirmed())
+		t.Fail()
+	}
+
+	// The correction is predicted like any other move, and a late outcome for a rolled back move is ignored
+	p.Predict(6, pos, replayed)
+	if p.Commit(4) {
+		fmt.Println("Fail, rolled back move was committed")
+		t.Fail()
+	}
+	if _, _, ok := p.Fail(5, stepOpenBoard); ok {
+		fmt.Println("Fail, rolled back move was rolled back again")
+		t.Fail()
+	}
+
+	// With nothing after it, a failed move goes back to the last committed position
+	pos, replayed, _ = p.Fail(6, stepOpenBoard)
+	if pos != (shared.Coord{2, 2}) || len(replayed) != 0 {
+		fmt.Println("Fail, failed move did not go back to the committed position:", pos, replayed)
+		t.Fail()
+	}
+
+	// A failed move that the moves after it undo leaves the wolf where it is, so nothing needs sending and the moves
+	// after it are still waited on
+	p.Predict(7, shared.Coord{2, 2}, []string{"left", "right"})
+	p.Predict(8, shared.Coord{2, 3}, []string{"up"})
+	if pos, _, resend := p.Fail(7, stepOpenBoard); resend || pos != (shared.Coord{2, 3}) {
+		fmt.Println("Fail, correction was sent for a rollback that did not move the wolf:", pos)
+		t.Fail()
+	}
+	if unconfirmed := p.Unconfirmed(); len(unconfirmed) != 1 || unconfirmed[0] != 8 || p.Last() != (shared.Coord{2, 3}) {
+		fmt.Println("Fail, moves after a rollback that did not move the wolf are no longer predicted:", unconfirmed)
+		t.Fail()
+	}
+}
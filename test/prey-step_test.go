@@ -0,0 +1,203 @@
+package test
+
+import (
+	"testing"
+	"fmt"
+	"bytes"
+	"../peer"
+	"../shared"
+	"../geometry"
+	"../wolferrors"
+)
+
+// Returns a ticking node with DeterministicPrey on, holding the given positions
+func createDeterministicNode(id string, locs map[string]shared.Coord) *peer.Node {
+	n := createTickingNode()
+	n.Config.GameId = "prey-step"
+	n.Config.DeterministicPrey = true
+	n.Config.PreySeed = 42
+	n.Config.InitState.CatchWorth = 1
+	n.Role.(*boardRole).id = id
+	n.Role.(*boardRole).gameState.PlayerScores.Data = make(map[string]int)
+	for player, loc := range locs {
+		n.Role.GameState().PlayerLocs.Data[player] = loc
+	}
+	return n
+}
+
+func TestPreyStepIsDeterministic(t *testing.T) {
+	grid := geometry.CreateNewGridManager(shared.InitialGameSettings{WindowsX: 300, WindowsY: 300})
+	wolves := []shared.Coord{{2, 5}, {5, 8}}
+	seen := make(map[shared.Coord]bool)
+	for tick := uint64(1); tick <= 20; tick++ {
+		pos := peer.PreyStep(7, tick, "prey", shared.Coord{5, 5}, wolves, &grid)
+		if again := peer.PreyStep(7, tick, "prey", shared.Coord{5, 5}, wolves, &grid); again != pos {
+			fmt.Println("Fail, the same step was worked out differently:", pos, again)
+			t.Fail()
+		}
+		valid := false
+		for _, cell := range grid.Neighbours(shared.Coord{5, 5}) {
+			valid = valid || cell == pos
+		}
+		if !valid {
+			fmt.Println("Fail, prey stepped more than one cell:", pos)
+			t.Fail()
+		}
+		seen[pos] = true
+	}
+	if len(seen) < 2 {
+		fmt.Println("Fail, prey took the same step at every tick:", seen)
+		t.Fail()
+	}
+
+	// A prey a wolf is standing on respawns far away
+	pos := peer.PreyStep(7, 1, "prey", shared.Coord{5, 5}, []shared.Coord{{5, 5}}, &grid)
+	if abs(pos.X-5) <= 2 && abs(pos.Y-5) <= 2 {
+		fmt.Println("Fail, caught prey did not respawn far away:", pos)
+		t.Fail()
+	}
+}
+
+func abs(num int) int {
+	if num < 0 {
+		return -num
+	}
+	return num
+}
+
+func TestNodesWorkOutTheSamePreySteps(t *testing.T) {
+	locs := map[string]shared.Coord{"prey": {5, 5}, "prey-2": {1, 8}, "2": {3, 3}}
+	n1 := createDeterministicNode("2", locs)
+	n2 := createDeterministicNode("3", locs)
+
+	n1.HandleReceivedTickedMove("2", &shared.Coord{3, 4}, 1, 2, nil)
+	n2.HandleReceivedTickedMove("2", &shared.Coord{3, 4}, 1, 2, nil)
+	n1.SimulateTo(5)
+	n2.SimulateTo(5)
+
+	if !bytes.Equal(n1.StateHash(), n2.StateHash()) {
+		fmt.Println("Fail, nodes worked out different prey steps:", n1.Role.GameState().PlayerLocs.Data,
+			n2.Role.GameState().PlayerLocs.Data)
+		t.Fail()
+	}
+	if n1.RW.PreySeq("prey") != 5 || n1.RW.PreySeq("prey-2") != 5 {
+		fmt.Println("Fail, prey steps were not numbered by tick:", n1.RW.PreySeq("prey"), n1.RW.PreySeq("prey-2"))
+		t.Fail()
+	}
+}
+
+func TestPreyStepsAreChecked(t *testing.T) {
+	locs := map[string]shared.Coord{"prey": {5, 5}, "2": {3, 3}}
+	n := createDeterministicNode("2", locs)
+	prey := createDeterministicNode("prey", locs)
+	introduce(n, prey)
+	n.SimulateTo(3)
+	prey.SimulateTo(3)
+
+	// The step the prey worked out for itself is the one the node worked out
+	pos := prey.Role.GameState().PlayerLocs.Data["prey"]
+	if err := n.HandleReceivedPreyStep("prey", pos, 3, prey.CreateMove(&pos)); err != nil {
+		fmt.Println("Fail, the prey's own step was rejected:", err)
+		t.Fail()
+	}
+
+	// Any other step is evidence of cheating
+	other := shared.Coord{pos.X, pos.Y + 1}
+	if pos.Y == 9 {
+		other.Y = pos.Y - 1
+	}
+	if _, ok := n.HandleReceivedPreyStep("prey", other, 3, prey.CreateMove(&other)).(wolferrors.PreyStepError); !ok {
+		fmt.Println("Fail, a step that was not worked out from the seed was accepted")
+		t.Fail()
+	}
+	if evidence := n.Evidence("prey"); len(evidence) != 1 || evidence[0].Kind != peer.PreyStepCheat {
+		fmt.Println("Fail, prey step cheat was not recorded:", evidence)
+		t.Fail()
+	}
+
+	// A step for a tick not simulated yet waits until it is
+	prey.SimulateTo(4)
+	from := prey.Role.GameState().PlayerLocs.Data["prey"]
+	prey.SimulateTo(5)
+	pos = prey.Role.GameState().PlayerLocs.Data["prey"]
+	grid := geometry.CreateNewGridManager(shared.InitialGameSettings{WindowsX: 300, WindowsY: 300})
+	var wrong shared.Coord
+	for _, cell := range grid.Neighbours(from) {
+		if cell != pos {
+			wrong = cell
+		}
+	}
+	n.HandleReceivedPreyStep("prey", wrong, 5, prey.CreateMove(&wrong))
+	n.SimulateTo(5)
+	if len(n.Evidence("prey")) != 2 {
+		fmt.Println("Fail, step was not checked once its tick was simulated")
+		t.Fail()
+	}
+
+	// Once this node's state disagrees with another node's, it can not tell who is right, and takes the prey's word
+	// for a step it could have taken
+	wolf := createCapturingNode("3")
+	n.NodeKeys["3"] = wolf.PubKey
+	bad := []byte("not our hash")
+	signed := wolf.SignStateHash(5, bad)
+	n.HandleReceivedStateHash("3", 5, bad, &signed)
+	if !n.RecentlyDiverged(5) {
+		fmt.Println("Fail, divergence was not noticed")
+		t.FailNow()
+	}
+	far := shared.Coord{9 - from.X, 9 - from.Y}
+	if _, ok := n.HandleReceivedPreyStep("prey", far, 5, prey.CreateMove(&far)).(wolferrors.PreyStepError); !ok {
+		fmt.Println("Fail, prey's step across the board broke the tie")
+		t.Fail()
+	}
+	if len(n.Evidence("prey")) != 3 {
+		fmt.Println("Fail, prey's step across the board was not recorded:", n.Evidence("prey"))
+		t.Fail()
+	}
+	if err := n.HandleReceivedPreyStep("prey", wrong, 5, prey.CreateMove(&wrong)); err != nil {
+		fmt.Println("Fail, prey's step did not break the tie:", err)
+		t.Fail()
+	}
+	if n.Role.GameState().PlayerLocs.Data["prey"] != wrong {
+		fmt.Println("Fail, prey's step was not taken:", n.Role.GameState().PlayerLocs.Data["prey"], wrong)
+		t.Fail()
+	}
+}
+
+func TestDeterministicCaptureWithoutPrey(t *testing.T) {
+	locs := map[string]shared.Coord{"prey": {5, 5}, "2": {0, 0}, "3": {9, 9}}
+	capturer := createDeterministicNode("2", locs)
+	confirmer := createDeterministicNode("3", locs)
+	introduce(capturer, confirmer)
+	capturer.OtherNodes["3"] = nil
+	capturer.SimulateTo(3)
+	confirmer.SimulateTo(3)
+
+	// Nothing signed by the prey, and no confirmation from it: the position each node worked out is enough
+	pos := capturer.Role.GameState().PlayerLocs.Data["prey"]
+	event, err := capturer.ProposeCapture("prey", pos, 3)
+	if err != nil {
+		fmt.Println("Fail, could not propose capture at the worked out position:", err)
+		t.FailNow()
+	}
+	seenAt(capturer, pos, confirmer)
+	confirmation, err := confirmer.ConfirmCapture(event)
+	if err != nil {
+		fmt.Println("Fail, capture at the worked out position was not confirmed:", err)
+		t.FailNow()
+	}
+	if capturer.HandleReceivedCaptureConfirmation("3", "prey", 3, confirmation) == nil {
+		fmt.Println("Fail, capture was not certified without the prey")
+		t.Fail()
+	}
+
+	// A capture where the prey was not is still rejected
+	wrong := shared.Coord{pos.X + 1, pos.Y}
+	if pos.X == 9 {
+		wrong.X = pos.X - 1
+	}
+	if _, err := capturer.ProposeCapture("prey", wrong, 3); err == nil {
+		fmt.Println("Fail, proposed capture where the prey was not")
+		t.Fail()
+	}
+}
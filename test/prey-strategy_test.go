@@ -0,0 +1,110 @@
+package test
+
+import (
+	"testing"
+	"fmt"
+	"../geometry"
+	"../shared"
+	"../wolferrors"
+	l "../prey/impl"
+)
+
+// Returns a view of a 10x10 board with the given walls
+func createView(prey shared.Coord, wolves []shared.Coord, walls []shared.Coord) l.PreyView {
+	settings := shared.InitialGameSettings{WindowsX: 300, WindowsY: 300, WallCoordinates: walls, ScoreboardWidth: 200}
+	return l.PreyView{Grid: geometry.CreateNewGridManager(settings), Prey: prey, Wolves: wolves}
+}
+
+func TestSelectPreyStrategy(t *testing.T) {
+	for name := range l.PreyStrategies {
+		if strategy, err := l.SelectPreyStrategy(name); strategy == nil || err != nil {
+			fmt.Println("Fail, could not select strategy", name, err)
+			t.Fail()
+		}
+	}
+	if strategy, err := l.SelectPreyStrategy(""); err != nil {
+		fmt.Println("Fail, could not select the default strategy:", err)
+		t.Fail()
+	} else if _, ok := strategy.(l.GreedyFleeStrategy); !ok {
+		fmt.Println("Fail, default strategy is not the greedy flee:", strategy)
+		t.Fail()
+	}
+	if _, err := l.SelectPreyStrategy("teleport"); err == nil {
+		fmt.Println("Fail, selected a strategy that does not exist")
+		t.Fail()
+	} else if _, ok := err.(wolferrors.UnknownPreyStrategyError); !ok {
+		fmt.Println("Fail, wrong error for a strategy that does not exist:", err)
+		t.Fail()
+	}
+}
+
+func TestRandomStrategy(t *testing.T) {
+	view := createView(shared.Coord{5, 5}, []shared.Coord{{5, 6}}, nil)
+	for i := 0; i < 20; i++ {
+		if dir := (l.RandomStrategy{}).NextMove(view); l.Step(view.Prey, dir) == view.Prey {
+			fmt.Println("Fail, random strategy did not move:", dir)
+			t.Fail()
+		}
+	}
+}
+
+func TestGreedyFleeStrategy(t *testing.T) {
+	strategy := l.GreedyFleeStrategy{}
+	if dir := strategy.NextMove(createView(shared.Coord{5, 5}, []shared.Coord{{4, 5}}, nil)); dir != "right" {
+		fmt.Println("Fail, greedy flee did not step away from the wolf:", dir)
+		t.Fail()
+	}
+	// Boxed in on every side, there is nowhere to go
+	walls := []shared.Coord{{4, 5}, {6, 5}, {5, 4}, {5, 6}}
+	if dir := strategy.NextMove(createView(shared.Coord{5, 5}, []shared.Coord{{0, 0}}, walls)); dir != "still" {
+		fmt.Println("Fail, greedy flee moved into a wall:", dir)
+		t.Fail()
+	}
+}
+
+func TestBFSFleeStrategy(t *testing.T) {
+	// A wall along y = 6 with a gap at x = 9: the wolf just over the wall has to walk around through the gap, so the
+	// prey should not head towards it
+	var walls []shared.Coord
+	for x := 0; x < 9; x++ {
+		walls = append(walls, shared.Coord{x, 6})
+	}
+	view := createView(shared.Coord{5, 5}, []shared.Coord{{5, 7}}, walls)
+	if dir := (l.BFSFleeStrategy{}).NextMove(view); dir != "left" && dir != "down" {
+		fmt.Println("Fail, BFS flee did not move away from the wolf's way around the wall:", dir)
+		t.Fail()
+	}
+
+	// In a corridor along y = 0, the prey runs away from the wolf even though the cells behind it are a dead end
+	walls = []shared.Coord{{1, 1}, {2, 1}, {3, 1}, {4, 1}, {5, 1}}
+	view = createView(shared.Coord{3, 0}, []shared.Coord{{5, 0}}, walls)
+	if dir := (l.BFSFleeStrategy{}).NextMove(view); dir != "left" {
+		fmt.Println("Fail, BFS flee did not run down the corridor:", dir)
+		t.Fail()
+	}
+}
+
+func TestLookaheadStrategy(t *testing.T) {
+	strategy := l.LookaheadStrategy{Depth: 2, Wolves: 2}
+
+	// Stepping down walks into the wolf's reach
+	view := createView(shared.Coord{5, 5}, []shared.Coord{{5, 3}}, nil)
+	if dir := strategy.NextMove(view); l.Step(view.Prey, dir) == (shared.Coord{5, 4}) {
+		fmt.Println("Fail, lookahead stepped next to the wolf")
+		t.Fail()
+	}
+
+	// Between two wolves, the only way out is sideways
+	view = createView(shared.Coord{5, 5}, []shared.Coord{{3, 5}, {7, 5}}, nil)
+	if dir := strategy.NextMove(view); dir != "up" && dir != "down" {
+		fmt.Println("Fail, lookahead did not escape the pincer:", dir)
+		t.Fail()
+	}
+
+	// A far away third wolf does not change the choice, and is not searched
+	view = createView(shared.Coord{5, 5}, []shared.Coord{{3, 5}, {7, 5}, {0, 9}}, nil)
+	if dir := strategy.NextMove(view); dir != "down" {
+		fmt.Println("Fail, lookahead did not move away from the third wolf:", dir)
+		t.Fail()
+	}
+}
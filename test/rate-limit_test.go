@@ -0,0 +1,75 @@
+package test
+
+import (
+	"testing"
+	"fmt"
+	"time"
+	"../peer"
+	"../shared"
+)
+
+func TestRateLimitStateRequests(t *testing.T) {
+	limits := peer.RateLimits{
+		Budgets:   map[peer.MessageClass]peer.Budget{peer.StateRequestClass: {Rate: 0.001, Burst: 2}},
+		MuteAfter: 2,
+		MuteFor:   time.Minute,
+	}
+	rl := peer.NewRateLimiter(limits)
+
+	for i := 0; i < 2; i++ {
+		if !rl.Allow("1", "127.0.0.1:1000", shared.GameStateReqMessage) {
+			fmt.Println("Fail, request within burst was dropped")
+			t.Fail()
+		}
+	}
+	if rl.Allow("1", "127.0.0.1:1000", shared.GameStateReqMessage) {
+		fmt.Println("Fail, request over budget was allowed")
+		t.Fail()
+	}
+	if rl.Dropped()[peer.StateRequestClass] != 1 {
+		fmt.Println("Fail, dropped request was not counted")
+		t.Fail()
+	}
+
+	// Moves have their own budget, and a different sender has its own buckets
+	if !rl.Allow("1", "127.0.0.1:1000", shared.MoveMessage) {
+		fmt.Println("Fail, move was charged to the state request budget")
+		t.Fail()
+	}
+	if !rl.Allow("2", "127.0.0.1:2000", shared.GameStateReqMessage) {
+		fmt.Println("Fail, another sender was limited")
+		t.Fail()
+	}
+}
+
+func TestRateLimitMute(t *testing.T) {
+	limits := peer.RateLimits{
+		Budgets:   map[peer.MessageClass]peer.Budget{peer.StateRequestClass: {Rate: 0.001, Burst: 1}},
+		MuteAfter: 2,
+		MuteFor:   time.Minute,
+	}
+	rl := peer.NewRateLimiter(limits)
+
+	for i := 0; i < 3; i++ {
+		rl.Allow("1", "127.0.0.1:1000", shared.GameStateReqMessage)
+	}
+
+	// Muted by address, even when claiming another identifier
+	if rl.Allow("3", "127.0.0.1:1000", shared.MoveMessage) {
+		fmt.Println("Fail, muted address was allowed to send")
+		t.Fail()
+	}
+	// The identifier the flood claimed is not checked, so the node it belongs to is not muted along with it
+	if !rl.Allow("1", "127.0.0.1:3000", shared.MoveMessage) {
+		fmt.Println("Fail, node was muted by a flood claiming its identifier")
+		t.Fail()
+	}
+	if rl.MutedDrops() != 1 {
+		fmt.Println("Fail, messages from muted senders were not counted", rl.MutedDrops())
+		t.Fail()
+	}
+	if len(rl.Muted()) != 1 {
+		fmt.Println("Fail, expected only the address to be muted", rl.Muted())
+		t.Fail()
+	}
+}
@@ -28,7 +28,7 @@ func TestValidPreyCapture (t *testing.T) {
 	node1.GameState.PlayerLocs.Data["prey"] = shared.Coord{6, 6}
 	node1.GameState.PlayerLocs.Data[node1.Identifier] = shared.Coord{6,6}
 
-	err := node1.GetNodeInterface().CheckGotPrey(node1.GameState.PlayerLocs.Data[node1.Identifier])
+	_, err := node1.GetNodeInterface().CheckGotPrey(node1.GameState.PlayerLocs.Data[node1.Identifier])
 	if err != nil {
 		t.Fail()
 	}
@@ -53,7 +53,7 @@ func TestInvalidPreyCapture (t *testing.T) {
 	node1.GameState.PlayerLocs.Data["prey"] = shared.Coord{8, 6}
 	node1.GameState.PlayerLocs.Data[node1.Identifier] = shared.Coord{6,6}
 
-	err := node1.GetNodeInterface().CheckGotPrey(node1.GameState.PlayerLocs.Data[node1.Identifier])
+	_, err := node1.GetNodeInterface().CheckGotPrey(node1.GameState.PlayerLocs.Data[node1.Identifier])
 	if err == nil {
 		t.Fail()
 	}
@@ -93,7 +93,18 @@ func TestNodeToNodeSendScore(t *testing.T) {
 	testCoord := shared.Coord{5,5}
 	node2.GameState.PlayerLocs.Data["prey"] = shared.Coord{5, 5}
 
-	err := n2.HandleCapturedPreyRequest(node1.Identifier, &testCoord, 1, uint64(9))
+	// A stand-in prey confirms the capture
+	prey := createCapturingNode("prey")
+	prey.Config = n1.Config
+	introduce(n1.Node, n2.Node, prey)
+	event, err := certifiedCapture(n1.Node, prey, testCoord, uint64(9))
+	if event == nil {
+		fmt.Println("Error in recording a valid capture")
+		fmt.Println(err)
+		t.Fail()
+		return
+	}
+	err = n2.HandleCapturedPreyRequest(event)
 	if err != nil {
 		fmt.Println("Error in sending a valid prey & score")
 		fmt.Println(err)
@@ -132,23 +143,30 @@ func TestNodeToNodeSendingInvalidScore(t *testing.T) {
 		t.Fail()
 	}
 
-	// Test sending a move from one node to another
+	// Test sending a capture that claims more than a catch is worth
 	testCoord := shared.Coord{7,1}
-	n1.SendPreyCaptureToNodes(&testCoord, 1)
-	time.Sleep(200*time.Millisecond)
-
-	err := n2.HandleCapturedPreyRequest(node1.Identifier, &testCoord, 4, uint64(6))
+	prey := createCapturingNode("prey")
+	prey.Config = n1.Config
+	introduce(n1.Node, n2.Node, prey)
+	event, err := certifiedCapture(n1.Node, prey, testCoord, uint64(6))
+	if event == nil {
+		fmt.Println("Error in recording a capture")
+		fmt.Println(err)
+		t.Fail()
+		return
+	}
+	event.Worth = 4
+	err = n2.HandleCapturedPreyRequest(event)
 	if err == nil {
 		fmt.Println("Error in sending an invalid prey & score 1")
 		fmt.Println(err)
 		t.Fail()
 	}
 
-	testCoord = shared.Coord{5,5}
-	n1.SendPreyCaptureToNodes(&testCoord, 3)
-	time.Sleep(300*time.Millisecond)
-
-	err = n2.HandleCapturedPreyRequest(node1.Identifier, &testCoord, 3, uint64(2))
+	// Test sending a capture moved to somewhere the prey never signed
+	event.Worth = n1.Config.InitState.CatchWorth
+	event.PreyPos = shared.Coord{5,5}
+	err = n2.HandleCapturedPreyRequest(event)
 	if err == nil {
 		fmt.Println("Error in sending an invalid prey & score 2")
 		fmt.Println(err)
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	key "../key-helpers"
 	l "../logic/impl"
+	"../peer"
 	"../shared"
 	"context"
 )
@@ -43,7 +44,7 @@ func TestNodeToFakeMove(t *testing.T) {
 	testCoord := shared.Coord{7,7}
 	moveId := n1.CreateMove(&testCoord)
 	moveId.R = "Hello world"
-	message := l.NodeMessage{
+	message := peer.NodeMessage{
 		MessageType: "move",
 		Identifier:  n1.PlayerNode.Identifier,
 		Move:        moveId,
@@ -51,7 +52,7 @@ func TestNodeToFakeMove(t *testing.T) {
 		Seq:         2,
 	}
 	toSend := n1.Log.PrepareSend("prepare", message)
-	n1.MessagesToSend <- &l.PendingMessage{Recipient: "all", Message: toSend}
+	n1.MessagesToSend <- &peer.PendingMessage{Recipient: "all", Message: toSend}
 	time.Sleep(300*time.Millisecond)
 
 	if n2.PlayerNode.GameState.PlayerLocs.Data[node1.Identifier] == testCoord {
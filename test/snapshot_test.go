@@ -0,0 +1,54 @@
+package test
+
+import (
+	"testing"
+	"fmt"
+	"../shared"
+)
+
+func TestSnapshotDeltas(t *testing.T) {
+	sender := createBoardNode(shared.GameConfig{}, map[string]shared.Coord{"2": {2, 2}, "4": {4, 4}, "prey": {9, 9}})
+	receiver := createBoardNode(shared.GameConfig{}, make(map[string]shared.Coord))
+	sender.Role.GameState().PlayerLocs.Seq = map[string]uint64{"2": 1, "4": 1, "prey": 1}
+	sender.Role.GameState().PlayerScores.Data["2"] = 1
+
+	full := sender.SnapshotSince(receiver.SyncedVersion("sender"))
+	receiver.HandleReceivedGameState("sender", full)
+	if full.Base != 0 || receiver.SyncedVersion("sender") != full.Version {
+		fmt.Println("Fail, joining node did not get a full snapshot")
+		t.Fail()
+	}
+	if same := sender.SnapshotSince(full.Version); len(same.Locs)+len(same.Scores)+len(same.Removed) != 0 {
+		fmt.Println("Fail, delta for an unchanged gamestate is not empty:", same)
+		t.Fail()
+	}
+
+	// While the receiver is away, one wolf moves and the prey is captured
+	locs := &sender.Role.GameState().PlayerLocs
+	locs.Data["4"] = shared.Coord{4, 5}
+	locs.Seq["4"] = 2
+	delete(locs.Data, "prey")
+	delete(locs.Seq, "prey")
+
+	delta := sender.SnapshotSince(receiver.SyncedVersion("sender"))
+	if delta.Base != full.Version || len(delta.Locs) != 1 || len(delta.Scores) != 0 || len(delta.Removed) != 1 {
+		fmt.Println("Fail, delta does not hold only what changed:", delta)
+		t.Fail()
+	}
+	receiver.HandleReceivedGameState("sender", delta)
+	got := receiver.Role.GameState().PlayerLocs.Data
+	if got["4"] != (shared.Coord{4, 5}) || got["2"] != (shared.Coord{2, 2}) || len(got) != 2 {
+		fmt.Println("Fail, receiver did not catch up from the delta:", got)
+		t.Fail()
+	}
+
+	// A delta against a version the receiver never synced with is not merged
+	locs.Data["2"] = shared.Coord{2, 3}
+	locs.Seq["2"] = 2
+	stale := sender.SnapshotSince(full.Version)
+	receiver.HandleReceivedGameState("sender", stale)
+	if receiver.Role.GameState().PlayerLocs.Data["2"] != (shared.Coord{2, 2}) {
+		fmt.Println("Fail, delta against the wrong version was merged")
+		t.Fail()
+	}
+}
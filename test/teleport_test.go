@@ -0,0 +1,115 @@
+package test
+
+import (
+	"testing"
+	"encoding/json"
+	"fmt"
+	"../peer"
+	"../shared"
+	"../wolferrors"
+)
+
+func TestTeleportRejected(t *testing.T) {
+	n := createBoardNode(shared.GameConfig{}, make(map[string]shared.Coord))
+
+	n.HandleReceivedMoveNL("2", &shared.Coord{1, 1}, 1)
+	err := n.HandleReceivedMoveNL("2", &shared.Coord{5, 5}, 2)
+	if _, ok := err.(wolferrors.TeleportError); !ok {
+		fmt.Println("Fail, wolf jumping across the board was accepted:", err)
+		t.Fail()
+	}
+	evidence := n.Evidence("2")
+	if len(evidence) != 1 || evidence[0].Kind != peer.TeleportCheat || evidence[0].Seq != 2 {
+		fmt.Println("Fail, teleport was not recorded as evidence:", evidence)
+		t.Fail()
+	}
+
+	// Having missed two moves, the wolf can have moved three cells by its next one
+	if err := n.HandleReceivedMoveNL("2", &shared.Coord{2, 3}, 4); err != nil {
+		fmt.Println("Fail, catching up over a gap in sequence numbers was rejected:", err)
+		t.Fail()
+	}
+
+	// The prey respawns anywhere
+	n.HandleReceivedMoveNL("prey", &shared.Coord{1, 1}, 1)
+	if err := n.HandleReceivedMoveNL("prey", &shared.Coord{8, 8}, 2); err != nil {
+		fmt.Println("Fail, prey respawn was rejected:", err)
+		t.Fail()
+	}
+}
+
+func TestTeleportGapLimitedByMoveInterval(t *testing.T) {
+	n := createBoardNode(shared.GameConfig{MoveInterval: 1000}, make(map[string]shared.Coord))
+
+	// Skipping sequence numbers does not buy a wolf more cells than it had the time to move
+	n.HandleReceivedMoveNL("2", &shared.Coord{1, 1}, 1)
+	err := n.HandleReceivedMoveNL("2", &shared.Coord{1, 6}, 6)
+	if _, ok := err.(wolferrors.TeleportError); !ok {
+		fmt.Println("Fail, wolf skipping sequence numbers was accepted:", err)
+		t.Fail()
+	}
+}
+
+func TestReplayedSequenceNumberRejected(t *testing.T) {
+	n := createBoardNode(shared.GameConfig{}, make(map[string]shared.Coord))
+
+	// A second move reusing the last accepted sequence number can not take the wolf anywhere
+	n.HandleReceivedMoveNL("2", &shared.Coord{1, 1}, 1)
+	err := n.HandleReceivedMoveNL("2", &shared.Coord{8, 8}, 1)
+	if _, ok := err.(wolferrors.ReplayedMoveError); !ok {
+		fmt.Println("Fail, move reusing a sequence number was accepted:", err)
+		t.Fail()
+	}
+	if pos := n.Role.GameState().PlayerLocs.Data["2"]; pos != (shared.Coord{1, 1}) {
+		fmt.Println("Fail, move reusing a sequence number was applied:", pos)
+		t.Fail()
+	}
+	if pos, _ := n.RW.PositionAt("2", 1); pos != (shared.Coord{1, 1}) {
+		fmt.Println("Fail, move reusing a sequence number was added to the running window:", pos)
+		t.Fail()
+	}
+	evidence := n.Evidence("2")
+	if len(evidence) != 1 || evidence[0].Kind != peer.TeleportCheat || evidence[0].Seq != 1 {
+		fmt.Println("Fail, move reusing a sequence number was not recorded as evidence:", evidence)
+		t.Fail()
+	}
+
+	// The same move twice is rejected too, but may be a duplicated datagram, so it is not evidence
+	if _, ok := n.HandleReceivedMoveNL("2", &shared.Coord{1, 1}, 1).(wolferrors.ReplayedMoveError); !ok {
+		fmt.Println("Fail, repeated move was accepted twice")
+		t.Fail()
+	}
+	if evidence := n.Evidence("2"); len(evidence) != 1 {
+		fmt.Println("Fail, repeated move was recorded as evidence:", evidence)
+		t.Fail()
+	}
+}
+
+func TestMoveRejectionSigned(t *testing.T) {
+	rejecter := createCapturingNode("3")
+	wolf := createCapturingNode("2")
+	introduce(rejecter, wolf)
+
+	rejection := shared.MoveRejection{Rejecter: "3", Identifier: "2", Seq: 4, Move: shared.Coord{5, 5}, Reason: "far"}
+	rejectionBytes, _ := json.Marshal(rejection)
+	signed := rejecter.SignBytes(rejectionBytes)
+	if got := wolf.HandleMoveRejection("3", &signed); got == nil || *got != rejection {
+		fmt.Println("Fail, signed move rejection was not accepted:", got)
+		t.Fail()
+	}
+
+	// A rejection can not be passed off as coming from another node
+	if got := wolf.HandleMoveRejection("prey", &signed); got != nil {
+		fmt.Println("Fail, move rejection signed by another node was accepted")
+		t.Fail()
+	}
+
+	// Nor can it come from a node whose key is not known
+	rejection.Rejecter = "5"
+	rejectionBytes, _ = json.Marshal(rejection)
+	signed = createCapturingNode("5").SignBytes(rejectionBytes)
+	if got := wolf.HandleMoveRejection("5", &signed); got != nil {
+		fmt.Println("Fail, move rejection from a node with no known key was accepted")
+		t.Fail()
+	}
+}
@@ -0,0 +1,88 @@
+package test
+
+import (
+	"testing"
+	"fmt"
+	"bytes"
+	"time"
+	"net"
+	key "../key-helpers"
+	"../peer"
+	"../shared"
+	"../geometry"
+	"../wolferrors"
+)
+
+func createTickingNode() *peer.Node {
+	pub, priv := key.GenerateKeys()
+	n := peer.CreateNode(pub, priv, ":8081")
+	n.LocalAddr = &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 15701}
+	n.Config = shared.GameConfig{TickInterval: 100, Epoch: time.Now().UnixNano()}
+	n.SetRole(&boardRole{
+		gameState: shared.GameState{PlayerLocs: shared.PlayerLockMap{Data: make(map[string]shared.Coord)}},
+		geo:       geometry.CreateNewGridManager(shared.InitialGameSettings{WindowsX: 300, WindowsY: 300}),
+	})
+	return n
+}
+
+func TestTicksApplyMovesInTheSameOrder(t *testing.T) {
+	n1 := createTickingNode()
+	n2 := createTickingNode()
+
+	// Two moves by the same wolf and one by another, arriving in a different order at each node
+	n1.HandleReceivedTickedMove("prey", &shared.Coord{5, 5}, 1, 3, nil)
+	n1.HandleReceivedTickedMove("prey", &shared.Coord{5, 6}, 2, 3, nil)
+	n1.HandleReceivedTickedMove("2", &shared.Coord{1, 1}, 1, 4, nil)
+	n2.HandleReceivedTickedMove("2", &shared.Coord{1, 1}, 1, 4, nil)
+	n2.HandleReceivedTickedMove("prey", &shared.Coord{5, 6}, 2, 3, nil)
+	n2.HandleReceivedTickedMove("prey", &shared.Coord{5, 5}, 1, 3, nil)
+
+	n1.SimulateTo(3)
+	n2.SimulateTo(3)
+	if n1.Role.GameState().PlayerLocs.Data["2"] == (shared.Coord{1, 1}) {
+		fmt.Println("Fail, move was applied before its tick")
+		t.Fail()
+	}
+	n1.SimulateTo(4)
+	n2.SimulateTo(4)
+	if !bytes.Equal(n1.StateHash(), n2.StateHash()) {
+		fmt.Println("Fail, nodes diverged after the same ticks")
+		t.Fail()
+	}
+	if n1.Role.GameState().PlayerLocs.Data["prey"] != (shared.Coord{5, 6}) {
+		fmt.Println("Fail, moves in a tick were not applied in sequence order")
+		t.Fail()
+	}
+
+	if _, ok := n1.HandleReceivedTickedMove("2", &shared.Coord{1, 2}, 2, 4, nil).(wolferrors.LateInputError); !ok {
+		fmt.Println("Fail, move for a tick that was already simulated was accepted")
+		t.Fail()
+	}
+
+	n1.NodeKeys["2"] = n2.PubKey
+	n1.NodeKeys["3"] = n2.PubKey
+	n1.NodeKeys["prey"] = n2.PubKey
+	bad := []byte("not our state")
+	signedBad := n2.SignStateHash(4, bad)
+	n1.HandleReceivedStateHash("3", 4, bad, &signedBad)
+	if n1.Divergences() != 1 {
+		fmt.Println("Fail, mismatched state hash was not counted")
+		t.Fail()
+	}
+	signedGood := n2.SignStateHash(4, n2.StateHash())
+	n1.HandleReceivedStateHash("2", 4, n2.StateHash(), &signedGood)
+	if n1.Divergences() != 1 {
+		fmt.Println("Fail, matching state hash was counted as diverged")
+		t.Fail()
+	}
+
+	// A hash that was not signed by the node it claims to be from, or that is from a prey, is not compared
+	forged := createTickingNode().SignStateHash(4, bad)
+	n1.HandleReceivedStateHash("2", 4, bad, &signedGood)
+	n1.HandleReceivedStateHash("2", 4, bad, &forged)
+	n1.HandleReceivedStateHash("prey", 4, bad, &signedBad)
+	if n1.Divergences() != 1 {
+		fmt.Println("Fail, state hash that can not be trusted was counted as diverged")
+		t.Fail()
+	}
+}
@@ -1,14 +1,14 @@
 package test
 import (
-	l "../logic/impl"
+	"../peer"
 	"testing"
 	"../shared"
 	"fmt"
 	"time"
 )
 
-func before()l.RunningWindow {
-	return l.RunningWindow{Map:make(map[string][l.NUMMOVESTOKEEP]l.MoveSeq)}
+func before()peer.RunningWindow {
+	return peer.RunningWindow{Map:make(map[string][]peer.MoveSeq)}
 }
 
 
@@ -115,8 +115,45 @@ func TestPrey(t *testing.T) {
 			t.FailNow()
 		}
 	}
-	if rw.PreySeq != 5{
+	if rw.PreySeq("prey") != 5{
 		t.FailNow()
 	}
 	fmt.Println("Passed")
-}
\ No newline at end of file
+}
+func TestWindowPolicies(t *testing.T) {
+	rw := before()
+	rw.Configure(shared.GameConfig{
+		PreyWindow: shared.WindowConfig{Moves: 2, Retain: 50},
+		WolfWindow: shared.WindowConfig{Moves: 3},
+	})
+
+	for i := 1; i <= 5; i++ {
+		rw.Add("id1", uint64(i), &shared.Coord{i, 0})
+		rw.Add("prey", uint64(i), &shared.Coord{0, i})
+	}
+	// Wolves keep just their last three moves; the prey keeps every move from the last 50ms
+	if rw.Has("id1", 2) || !rw.Has("id1", 3) {
+		fmt.Println("Fail, wolf window did not keep exactly three moves")
+		t.Fail()
+	}
+	if pos, ok := rw.PositionAt("prey", 1); !ok || pos != (shared.Coord{0, 1}) {
+		fmt.Println("Fail, recent prey move fell out of the window:", pos, ok)
+		t.Fail()
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	rw.Add("prey", 6, &shared.Coord{0, 6})
+	if rw.Has("prey", 4) || !rw.Has("prey", 5) {
+		fmt.Println("Fail, old prey moves were not dropped down to the policy's count")
+		t.Fail()
+	}
+	recent := rw.Within("prey", 30*time.Millisecond)
+	if len(recent) != 1 || recent[0].Seq != 6 || recent[0].Pos != (shared.Coord{0, 6}) {
+		fmt.Println("Fail, wrong prey moves within the last 30ms:", recent)
+		t.Fail()
+	}
+	if oldest, ok := rw.OldestSeq("prey"); !ok || oldest != 5 {
+		fmt.Println("Fail, wrong oldest prey move:", oldest)
+		t.Fail()
+	}
+}
@@ -26,6 +26,72 @@ func (e InvalidMoveError) Error() string {
 	return fmt.Sprintf("WolfPack: invalid move at [%s]", string(e))
 }
 
+type MoveCommitMismatchError string
+
+func (e MoveCommitMismatchError) Error() string {
+	return fmt.Sprintf("WolfPack: move does not match a move commit received for it [%s]", string(e))
+}
+
+type LateRevealError string
+
+func (e LateRevealError) Error() string {
+	return fmt.Sprintf("WolfPack: move was revealed too long after it was committed to [%s]", string(e))
+}
+
+type LateInputError string
+
+func (e LateInputError) Error() string {
+	return fmt.Sprintf("WolfPack: move arrived after its tick was simulated [%s]", string(e))
+}
+
+type CellOccupiedError string
+
+func (e CellOccupiedError) Error() string {
+	return fmt.Sprintf("WolfPack: cell is occupied by [%s]", string(e))
+}
+
+type CaptureTieLostError string
+
+func (e CaptureTieLostError) Error() string {
+	return fmt.Sprintf("WolfPack: prey was captured by [%s] in the same round", string(e))
+}
+
+type UncertifiedCaptureError string
+
+func (e UncertifiedCaptureError) Error() string {
+	return fmt.Sprintf("WolfPack: capture [%s] was not confirmed by a quorum including the prey", string(e))
+}
+
+type MoveTooFastError string
+
+func (e MoveTooFastError) Error() string {
+	return fmt.Sprintf("WolfPack: player [%s] is moving faster than the game allows", string(e))
+}
+
+type TeleportError string
+
+func (e TeleportError) Error() string {
+	return fmt.Sprintf("WolfPack: player [%s] moved further than it could have since its last move", string(e))
+}
+
+type CapturePeersError string
+
+func (e CapturePeersError) Error() string {
+	return fmt.Sprintf("WolfPack: capture was made knowing of fewer nodes than there are now [%s]", string(e))
+}
+
+type BouncedMoveError string
+
+func (e BouncedMoveError) Error() string {
+	return fmt.Sprintf("WolfPack: player [%s] bounced back off another wolf", string(e))
+}
+
+type ReplayedMoveError string
+
+func (e ReplayedMoveError) Error() string {
+	return fmt.Sprintf("WolfPack: player [%s] sent a second move with the sequence number of its last one", string(e))
+}
+
 type OutOfBoundsError string
 
 func (e OutOfBoundsError) Error() string {
@@ -68,8 +134,31 @@ func (e UnknownKeyError) Error() string {
 	return fmt.Sprintf("WolfPack: unknown key [%s]", string(e))
 }
 
+type BannedKeyError string
+
+func (e BannedKeyError) Error() string {
+	return fmt.Sprintf("WolfPack: key was banned for cheating [%s]", string(e))
+}
+
+type UnprovenAccusationError string
+
+func (e UnprovenAccusationError) Error() string {
+	return fmt.Sprintf("WolfPack: not enough nodes accused player [%s] to ban it", string(e))
+}
+
 type UnknownSequenceError string
 
 func (e UnknownSequenceError) Error() string {
 	return fmt.Sprintf("WolfPack: unknown sequence number [%s]", string(e))
-}
\ No newline at end of file
+}
+type UnknownPreyStrategyError string
+
+func (e UnknownPreyStrategyError) Error() string {
+	return fmt.Sprintf("WolfPack: unknown prey strategy [%s]", string(e))
+}
+
+type PreyStepError string
+
+func (e PreyStepError) Error() string {
+	return fmt.Sprintf("WolfPack: prey step at tick [%s] is not the one worked out from the seed", string(e))
+}